@@ -0,0 +1,146 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+const badKey = "!BADKEY"
+
+// With returns a child logger which writes the given key-value pairs with
+// every entry, e.g. logger.With("service", "billing", log.Int("shard", 3)).
+// Arguments could be either a Field or a string key followed by its value,
+// a key without value is written under a numbered key such as "!BADKEY1".
+func (l *Logger) With(keysAndValues ...any) *Logger {
+	ctx := make(Context, len(keysAndValues)/2)
+	var parent Context
+	if lf := l.fields.Load(); lf != nil {
+		parent = lf.ctx
+	}
+	bad := 0
+	for i := 0; i < len(keysAndValues); i++ {
+		if f, ok := keysAndValues[i].(Field); ok {
+			ctx[f.Key] = f.value()
+			continue
+		}
+
+		key, ok := keysAndValues[i].(string)
+		if !ok || i == len(keysAndValues)-1 {
+			// NOTE: bad keys of the parent are kept as well
+			bad++
+			k := badKey + strconv.Itoa(bad)
+			for ctx.has(k) || parent.has(k) {
+				bad++
+				k = badKey + strconv.Itoa(bad)
+			}
+			ctx[k] = keysAndValues[i]
+			continue
+		}
+		ctx[key] = keysAndValues[i+1]
+		i++
+	}
+	return l.WithContext(ctx)
+}
+
+// WithContext returns a child logger which writes ctx with every entry.
+// The child shares the writer, formatter and level of its parent, its
+// fields are merged with the fields of the parent and encoded only once
+// per formatter.
+func (l *Logger) WithContext(ctx Context) *Logger {
+	merged := make(Context, len(ctx))
+	if lf := l.fields.Load(); lf != nil {
+		for k, v := range lf.ctx {
+			merged[k] = v
+		}
+	}
+	for k, v := range ctx {
+		merged[k] = v
+	}
+
+	child := l.child()
+	child.fields.Store(&loggerFields{ctx: merged})
+	return child
+}
+
+// WithCallerSkip returns a child logger which skips n more frames when
+// looking for the caller, it is meant for packages which wrap a Logger
+// in their own log functions
+func (l *Logger) WithCallerSkip(n int) *Logger {
+	child := l.child()
+	child.callerSkip += n
+	return child
+}
+
+func (l *Logger) child() *Logger {
+	child := &Logger{
+		root:       l.core(),
+		callerSkip: l.callerSkip,
+		name:       l.name,
+		lvl:        l.lvl,
+	}
+	child.callPath.Store(l.callPath.Load())
+	child.fields.Store(l.fields.Load())
+	return child
+}
+
+// loggerFields holds the fields of a child logger, ctx must never be
+// modified after creation
+type loggerFields struct {
+	ctx     Context
+	encoded atomic.Pointer[encodedFields]
+}
+
+// merge returns the fields overridden by ctx of an entry
+func (lf *loggerFields) merge(ctx Context) Context {
+	if len(ctx) == 0 {
+		return lf.ctx
+	}
+	merged := make(Context, len(lf.ctx)+len(ctx))
+	for k, v := range lf.ctx {
+		merged[k] = v
+	}
+	for k, v := range ctx {
+		merged[k] = v
+	}
+	return merged
+}
+
+// encodedFields caches ctx encoded by formatter
+type encodedFields struct {
+	formatter Formatter
+	buf       []byte
+}
+
+// apply attaches the fields to the entry, encoded by f if it is possible,
+// i.e. none of them is overridden by a field of the call site
+func (lf *loggerFields) apply(f Formatter, e *LogEntry) {
+	enc, ok := f.(contextEncoder)
+	if !ok || lf.ctx.shadowedBy(e.fields) {
+		e.WithContext(lf.merge(e.context))
+		return
+	}
+
+	cached := lf.encoded.Load()
+	if cached == nil || cached.formatter != f {
+		cached = &encodedFields{
+			formatter: f,
+			buf:       enc.encodeContext(nil, lf.ctx),
+		}
+		lf.encoded.Store(cached)
+	}
+	e.encoded = cached.buf
+}
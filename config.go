@@ -0,0 +1,323 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config describes a Logger, it could be loaded from JSON such as
+//
+//	{
+//	  "level": "info",
+//	  "format": "json",
+//	  "caller": true,
+//	  "outputs": [
+//	    {"path": "stdout"},
+//	    {"path": "/var/log/app/error.log", "level": "error",
+//	     "rotate": {"max_size": 104857600, "interval": "daily", "max_backups": 7, "compress": true}}
+//	  ],
+//	  "sampling": {"tick": "1s", "initial": 100, "thereafter": 100},
+//	  "fields": {"service": "billing"}
+//	}
+type Config struct {
+	// Level is INFO if it is not specified
+	Level Level `json:"level,omitempty"`
+	// Format is "text", "json" or "logfmt", text by default
+	Format string `json:"format,omitempty"`
+	// Color colors the text format
+	Color bool `json:"color,omitempty"`
+	// TimeFormat is a time layout, or one of "RFC3339" and "RFC3339Nano"
+	TimeFormat string `json:"time_format,omitempty"`
+	// Caller annotates entries with the caller
+	Caller bool `json:"caller,omitempty"`
+	// Outputs are stdout if there is none
+	Outputs  []OutputConfig  `json:"outputs,omitempty"`
+	Sampling *SamplingConfig `json:"sampling,omitempty"`
+	// Fields are written with every entry
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// OutputConfig describes a destination of entries
+type OutputConfig struct {
+	// Path is "stdout", "stderr" or a file to append to
+	Path string `json:"path"`
+	// Level filters the entries written to this output in addition to the
+	// level of the logger
+	Level Level `json:"level,omitempty"`
+	// Rotate writes the file by a RotateWriter, otherwise the file could
+	// be rotated externally, see ReopenWriter
+	Rotate *RotateConfig `json:"rotate,omitempty"`
+}
+
+// RotateConfig is the serializable form of RotateOptions
+type RotateConfig struct {
+	MaxSize    int64          `json:"max_size,omitempty"`
+	Interval   RotateInterval `json:"interval,omitempty"`
+	MaxBackups int            `json:"max_backups,omitempty"`
+	// MaxAge is a duration such as "168h"
+	MaxAge   string `json:"max_age,omitempty"`
+	Compress bool   `json:"compress,omitempty"`
+}
+
+// SamplingConfig describes the sampling of repeated entries, see
+// Logger.SetSampling
+type SamplingConfig struct {
+	// Tick is a duration such as "1s", one second if it is not specified
+	Tick       string `json:"tick,omitempty"`
+	Initial    int    `json:"initial"`
+	Thereafter int    `json:"thereafter"`
+}
+
+// LoadConfig reads a JSON config from path, unknown keys are rejected so
+// that typos do not pass silently
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(path, data)
+}
+
+func parseConfig(path string, data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	c := &Config{}
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("log: invalid config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Build returns a Logger described by the config
+func (c *Config) Build() (*Logger, error) {
+	l := NewLogger(os.Stdout, LogLevelDefault, CallPathDefault)
+	if err := c.Apply(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Apply reconfigures a live Logger, everything is prepared before any
+// change, so that the logger is left untouched if the config is invalid.
+// Files of outputs which are no longer configured are closed, the others
+// are kept open. Children created by With before Apply keep the former
+// fields.
+func (c *Config) Apply(l *Logger) error {
+	level := c.Level
+	if level == LogLevelUnspecified {
+		level = LogLevelDefault
+	}
+	timeFormat, err := parseTimeFormat(c.TimeFormat)
+	if err != nil {
+		return fmt.Errorf("log: invalid time_format %q: %w", c.TimeFormat, err)
+	}
+	formatter, err := newFormatter(c.Format, c.Color, timeFormat)
+	if err != nil {
+		return fmt.Errorf("log: invalid format %q: %w", c.Format, err)
+	}
+	tick := time.Duration(0)
+	if s := c.Sampling; s != nil {
+		if tick, err = parseDuration(s.Tick, time.Second); err != nil {
+			return fmt.Errorf("log: invalid sampling tick %q: %w", s.Tick, err)
+		}
+	}
+	var fields *loggerFields
+	if len(c.Fields) != 0 {
+		ctx := make(Context, len(c.Fields))
+		for k, v := range c.Fields {
+			ctx[k] = v
+		}
+		fields = &loggerFields{ctx: ctx}
+	}
+
+	core := l.core()
+	old, _ := core.GetWriter().(*MultiWriter)
+	w, err := c.openOutputs(old)
+	if err != nil {
+		return err
+	}
+
+	l.SetLevel(level)
+	core.SetFormatter(formatter)
+	core.caller.Store(c.Caller)
+	if s := c.Sampling; s != nil {
+		core.SetSampling(tick, s.Initial, s.Thereafter)
+	} else {
+		core.SetSampling(0, 0, 0)
+	}
+	l.fields.Store(fields)
+	core.SetWriter(w)
+
+	if old != nil {
+		// NOTE: wait for the write in progress, which may use the old writer
+		core.wmu.Lock()
+		core.wmu.Unlock()
+		old.closeExcept(w)
+	}
+	return nil
+}
+
+// openOutputs opens the outputs of the config, the ones of old which are
+// configured the same way are reused
+func (c *Config) openOutputs(old *MultiWriter) (*MultiWriter, error) {
+	reused := map[string]io.Writer{}
+	if old != nil {
+		for i, key := range old.keys {
+			reused[key] = old.outputs[i].Writer
+		}
+	}
+
+	outputs := c.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{Path: "stdout"}}
+	}
+
+	w := &MultiWriter{}
+	for _, o := range outputs {
+		key := o.key()
+		for _, k := range w.keys {
+			if k == key {
+				w.closeExcept(old)
+				return nil, fmt.Errorf("log: output %s is configured twice", o.Path)
+			}
+		}
+
+		writer, ok := reused[key]
+		if !ok {
+			var err error
+			if writer, err = o.open(); err != nil {
+				w.closeExcept(old)
+				return nil, fmt.Errorf("log: failed to open output %s: %w", o.Path, err)
+			}
+		}
+		w.outputs = append(w.outputs, Output{Writer: writer, Level: o.Level})
+		w.keys = append(w.keys, key)
+	}
+	return w, nil
+}
+
+// key identifies an output by the way its file is opened
+func (o *OutputConfig) key() string {
+	if o.Rotate == nil {
+		return o.Path
+	}
+	return fmt.Sprintf("%s%+v", o.Path, *o.Rotate)
+}
+
+func (o *OutputConfig) open() (io.Writer, error) {
+	switch o.Path {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	if r := o.Rotate; r != nil {
+		maxAge, err := parseDuration(r.MaxAge, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_age %q: %w", r.MaxAge, err)
+		}
+		return NewRotateWriter(o.Path, RotateOptions{
+			MaxSize:    r.MaxSize,
+			Interval:   r.Interval,
+			MaxBackups: r.MaxBackups,
+			MaxAge:     maxAge,
+			Compress:   r.Compress,
+		})
+	}
+	return NewReopenWriter(o.Path)
+}
+
+// parseDuration parses s, the empty s means def
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// closeExcept closes the outputs which are not shared with keep
+func (w *MultiWriter) closeExcept(keep *MultiWriter) {
+	for i, o := range w.outputs {
+		shared := false
+		if keep != nil {
+			for _, k := range keep.keys {
+				shared = shared || k == w.keys[i]
+			}
+		}
+		if !shared {
+			_ = closeOutput(o.Writer)
+		}
+	}
+}
+
+// DefaultWatchInterval is the interval of polling by WatchConfig
+const DefaultWatchInterval = 2 * time.Second
+
+// WatchConfig polls the config file at path and applies it to l whenever
+// its content changes, a non positive interval means DefaultWatchInterval.
+// An invalid config is reported to stderr and the logger keeps running
+// with the former one.
+func WatchConfig(path string, l *Logger, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	last, _ := os.ReadFile(path)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+
+			// NOTE: a missing file is likely being replaced, it is
+			// retried at the next tick
+			data, err := os.ReadFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+
+			c, err := parseConfig(path, data)
+			if err == nil {
+				err = c.Apply(l)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "log: failed to apply config %s: %s\n", path, err)
+				continue
+			}
+			l.notice("log config reloaded from " + path)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
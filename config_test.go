@@ -0,0 +1,155 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Config(t *testing.T) {
+	dir := t.TempDir()
+	all, errs := filepath.Join(dir, "all.log"), filepath.Join(dir, "error.log")
+	path := filepath.Join(dir, "log.json")
+	writeFile(t, path, `{
+	"level": "debug",
+	"format": "json",
+	"outputs": [
+		{"path": "`+all+`"},
+		{"path": "`+errs+`", "level": "error", "rotate": {"max_size": 1048576, "interval": "daily", "max_age": "168h"}}
+	],
+	"sampling": {"initial": 1, "thereafter": 0},
+	"fields": {"service": "billing"}
+}`)
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != LogLevelDebug || c.Outputs[1].Rotate.Interval != RotateDaily {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	logger, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.GetWriter().(*MultiWriter).Close()
+
+	logger.Debugln("debug")
+	logger.Debugln("debug")
+	logger.Errorln("error")
+	logger.With("shard", 3).Infoln("child")
+
+	lines := readLines(t, all)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 entries, got %q", lines)
+	}
+	entry := map[string]any{}
+	if err := json.Unmarshal([]byte(lines[2]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["service"] != "billing" || entry["shard"] != float64(3) || entry["msg"] != "child" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if lines := readLines(t, errs); len(lines) != 1 || !strings.Contains(lines[0], `"msg":"error"`) {
+		t.Fatalf("unexpected errors %q", lines)
+	}
+
+	for _, data := range []string{
+		`{"level": "loud"}`,
+		`{"format": "xml"}`,
+		`{"levle": "info"}`,
+		`{"sampling": {"tick": "often", "initial": 1}}`,
+	} {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := LoadConfig(path); err == nil {
+			err = c.Apply(logger)
+			if err == nil {
+				t.Fatalf("expected an error for %s", data)
+			}
+		}
+	}
+	bad := &Config{Outputs: []OutputConfig{{Path: all}, {Path: filepath.Join(dir, "missing", "x.log")}}}
+	if err := bad.Apply(logger); err == nil {
+		t.Fatal("expected an error for an output which could not be opened")
+	}
+	logger.Debugln("after invalid configs")
+	if lines := readLines(t, all); len(lines) != 4 {
+		t.Fatalf("invalid configs should leave the logger untouched, got %q", lines)
+	}
+}
+
+func Test_WatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	path := filepath.Join(dir, "log.json")
+	writeFile(t, path, `{"outputs": [{"path": "`+first+`"}]}`)
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.GetWriter().(*MultiWriter).Close()
+	stop := WatchConfig(path, logger, 10*time.Millisecond)
+	defer stop()
+
+	logger.Debugln("dropped before reload")
+	writeFile(t, path, `{"level": "debug", "outputs": [{"path": "`+second+`"}]}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readLines(t, second)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("config was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	logger.Debugln("written after reload")
+
+	if lines := readLines(t, first); len(lines) != 0 {
+		t.Fatalf("unexpected entries %q", lines)
+	}
+	lines := readLines(t, second)
+	if len(lines) != 2 || !strings.Contains(lines[0], "log config reloaded") || !strings.HasSuffix(lines[1], "msg=written after reload") {
+		t.Fatalf("unexpected entries %q", lines)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
@@ -14,15 +14,16 @@
 
 package log
 
-// UNSPECIFIED means no log level
+// UNSPECIFIED means no log level, the builtin levels are spaced so that
+// levels registered by RegisterLevel could be put between them
 const (
-	LogLevelUnspecified int = iota
-	LogLevelTrace
-	LogLevelDebug
-	LogLevelInfo
-	LogLevelWarn
-	LogLevelError
-	LogLevelFatal
+	LogLevelUnspecified Level = 0
+	LogLevelTrace       Level = 10
+	LogLevelDebug       Level = 20
+	LogLevelInfo        Level = 30
+	LogLevelWarn        Level = 40
+	LogLevelError       Level = 50
+	LogLevelFatal       Level = 60
 )
 
 const (
@@ -34,6 +35,22 @@ const (
 	EnvLogLevelFatal = "FATAL"
 )
 
+// environment variables read by NewLoggerFromEnv
+const (
+	// EnvLogLevel is a level name such as "debug"
+	EnvLogLevel = "LOG_LEVEL"
+	// EnvLogFormat is "text", "json" or "logfmt"
+	EnvLogFormat = "LOG_FORMAT"
+	// EnvLogColor is a boolean such as "true" or "0"
+	EnvLogColor = "LOG_COLOR"
+	// EnvLogTimeFormat is a time layout, or one of "RFC3339" and "RFC3339Nano"
+	EnvLogTimeFormat = "LOG_TIME_FORMAT"
+	// EnvLogCaller is a boolean, it enables the caller information
+	EnvLogCaller = "LOG_CALLER"
+	// EnvLogOutput is "stdout", "stderr" or a file path to append to
+	EnvLogOutput = "LOG_OUTPUT"
+)
+
 const (
 	// CallPath is The depth of a function is called
 	CallPathDepth1  = 1
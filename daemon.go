@@ -14,44 +14,19 @@
 
 package log
 
-/*
-import (
-	"bytes"
-	"time"
-)
+// flushDaemon writes the entries of the queue until it is closed
+func (l *Logger) flushDaemon(q *asyncQueue) {
+	defer close(q.done)
 
-const flushInterval = 30 * time.Second
-
-func (l *Logger) flushDaemon() error {
-	for _ = range time.NewTicker(flushInterval).C {
-		return l.lockAndFlushAll()
-	}
-	return nil
-}
-
-func (l *Logger) lockAndFlushAll() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.flushAll()
-}
-
-func (l *Logger) flushAll() error {
-	ch := l.Sink.Receiver()
-	var buf *bytes.Buffer
+	var (
+		level Level
+		buf   []byte
+		ok    bool
+	)
 	for {
-		select {
-		case e := <-ch:
-			{
-				buf = bytes.NewBuffer([]byte(e.fields.Msg))
-				if _, err := l.Writer.Write(buf.Bytes()); err != nil {
-					return err
-				}
-			}
-		default:
-			{
-
-			}
+		if level, buf, ok = q.pop(buf); !ok {
+			return
 		}
+		q.written(l.write(level, buf))
 	}
 }
-*/
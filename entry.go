@@ -15,25 +15,42 @@
 package log
 
 import (
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 type LogEntry struct {
 	buf []byte
 
-	color     bool
-	level     string
+	color    bool
+	level    string
+	severity Level
+	// name is the name of the logger created by Named
+	name      string
+	time      time.Time
 	timestamp []byte
 	msg       string
+	msgbuf    []byte
 	newline   bool
 
+	// caller information, file is empty if it is not collected
+	file     string
+	funcname string
+	line     int
+
+	// encoded is the context of a child logger encoded ahead of time by
+	// the same formatter, it is written before context
+	encoded []byte
 	context Context
+	fields  []Field
 }
 
 var epool = sync.Pool{
 	New: func() any {
 		return &LogEntry{
-			buf: make([]byte, 1024),
+			buf: make([]byte, 0, 1024),
 		}
 	},
 }
@@ -50,6 +67,23 @@ func (e *LogEntry) SetMsg(msg string) *LogEntry {
 
 func (e *LogEntry) SetLevel(lv string) *LogEntry {
 	e.level = lv
+	def, _ := LookupLevel(lv)
+	e.severity = def.Severity
+	return e
+}
+
+// setSeverity sets the level by its severity, which saves the lookup of
+// SetLevel
+func (e *LogEntry) setSeverity(level Level) *LogEntry {
+	e.level = getLogLevel(level)
+	e.severity = level
+	return e
+}
+
+func (e *LogEntry) SetCaller(file, funcname string, line int) *LogEntry {
+	e.file = file
+	e.funcname = funcname
+	e.line = line
 	return e
 }
 
@@ -71,7 +105,16 @@ func (e *LogEntry) render() *LogEntry {
 	e.buf = append(e.buf, e.level...)
 	e.buf = append(e.buf, "] "...)
 
-	// TODO: add a switch for context
+	if len(e.file) != 0 {
+		e.buf = append(e.buf, '[')
+		e.buf = append(e.buf, e.funcname...)
+		e.buf = append(e.buf, "] ["...)
+		e.buf = append(e.buf, e.file...)
+		e.buf = append(e.buf, ':')
+		e.buf = strconv.AppendInt(e.buf, int64(e.line), 10)
+		e.buf = append(e.buf, "] "...)
+	}
+
 	e.renderCtx()
 
 	e.buf = append(e.buf, "msg"...)
@@ -80,57 +123,44 @@ func (e *LogEntry) render() *LogEntry {
 	return e
 }
 
+// renderCtx renders context fields as sorted key=value pairs, every pair
+// is followed by a space so that msg comes right after
 func (e *LogEntry) renderCtx() {
-	// TODO: need check key msg
-	// NOTE: one alloc
-	// e.context["msg"] = e.msg
-	// e.context["level"] = e.level
-
-	// var (
-	// 	key   string
-	// 	value any
-	// )
-
-	// for key, value = range e.context {
-	// 	e.buf = append(e.buf, key...)
-	// 	e.buf = append(e.buf, ": "...)
-	// 	// TODO: special check for v
-	// 	// e.println(v)
-	// 	e.buf = append(e.buf, value.(string)...)
-	// 	key = key[:0]
-	// 	value = value.(string)[:0]
-	// }
+	e.buf = append(e.buf, e.encoded...)
+	e.buf = appendTextContext(e.buf, e.context)
+	e.buf = appendTextFields(e.buf, e.fields)
 }
 
-type palette []string
+func appendTextContext(dst []byte, ctx Context) []byte {
+	if len(ctx) == 0 {
+		return dst
+	}
 
-func (p palette) pair() (string, string) {
-	return p[0], p[1]
+	for _, k := range ctx.sortedKeys() {
+		if isReservedKey(k) {
+			dst = append(dst, fieldsPrefix...)
+		}
+		dst = appendTextString(dst, k)
+		dst = append(dst, '=')
+		dst = appendTextValue(dst, ctx[k])
+		dst = append(dst, ' ')
+	}
+	return dst
 }
 
-var (
-	blue palette = []string{"\033[31m", "\033[0m"}
-	red  palette = []string{"\033[1;34m", "\033[0m"}
-)
-
-func (e *LogEntry) renderc(color palette) *LogEntry {
-	prefix, suffix := color.pair()
-	e.buf = append(e.buf, prefix...)
+func (e *LogEntry) renderc(color string) *LogEntry {
+	e.buf = append(e.buf, color...)
 	e.render()
-	e.buf = append(e.buf, suffix...)
+	e.buf = append(e.buf, colorReset...)
 	return e
 }
 
+// colorize renders the entry in the color of its level, see LevelDef
 func (e *LogEntry) colorize() *LogEntry {
-	switch e.level {
-	case EnvLogLevelError:
-		e.renderc(blue)
-	case EnvLogLevelDebug:
-		e.renderc(red)
-	default:
-		e.render()
+	if def, ok := levelDef(e.severity); ok && def.Color != "" {
+		return e.renderc(def.Color)
 	}
-	return e
+	return e.render()
 }
 
 func (e *LogEntry) Bytes() []byte {
@@ -145,13 +175,22 @@ func (e *LogEntry) SetNewline() *LogEntry {
 func (e *LogEntry) reset() *LogEntry {
 	e.color = false
 	e.level = ""
+	e.severity = LogLevelUnspecified
+	e.name = ""
 	e.msg = ""
+	e.msgbuf = e.msgbuf[:0]
 	e.newline = false
+	e.file = ""
+	e.funcname = ""
+	e.line = 0
+	e.time = time.Time{}
 	e.timestamp = e.timestamp[:0]
 	e.buf = e.buf[:0]
-	for k, _ := range e.context {
-		delete(e.context, k)
-	}
+	// NOTE: both of them are owned by the caller
+	e.encoded = nil
+	e.context = nil
+	clear(e.fields)
+	e.fields = e.fields[:0]
 	return e
 }
 
@@ -162,3 +201,54 @@ func (e *LogEntry) WithContext(ctx Context) *LogEntry {
 	e.context = ctx
 	return e
 }
+
+// WithFields copies fields into the entry, so that the caller's slice
+// does not escape
+func (e *LogEntry) WithFields(fields []Field) *LogEntry {
+	e.fields = append(e.fields[:0], fields...)
+	return e
+}
+
+func (c Context) has(k string) bool {
+	_, ok := c[k]
+	return ok
+}
+
+// shadowedBy reports whether a key of c is a key of fields as well
+func (c Context) shadowedBy(fields []Field) bool {
+	if len(c) == 0 {
+		return false
+	}
+	for _, f := range fields {
+		if c.has(f.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowContext removes the keys of the fields from the context, so that
+// the fields of a call site override the context of a logger
+func (e *LogEntry) shadowContext() {
+	if !e.context.shadowedBy(e.fields) {
+		return
+	}
+	ctx := make(Context, len(e.context))
+	for k, v := range e.context {
+		ctx[k] = v
+	}
+	for _, f := range e.fields {
+		delete(ctx, f.Key)
+	}
+	e.context = ctx
+}
+
+// sortedKeys returns the keys of the context in a deterministic order
+func (c Context) sortedKeys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
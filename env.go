@@ -0,0 +1,127 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewLoggerFromEnv returns a Logger configured by the environment:
+//
+//	LOG_LEVEL=debug            level name, INFO by default
+//	LOG_FORMAT=json            text, json or logfmt, text by default
+//	LOG_COLOR=true             colors the text format
+//	LOG_TIME_FORMAT=RFC3339    a time layout, RFC3339 or RFC3339Nano
+//	LOG_CALLER=true            annotates entries with the caller
+//	LOG_OUTPUT=/var/log/a.log  stdout, stderr or a file to append to
+//
+// Unset or empty variables keep their defaults. Invalid values are
+// reported in the error, the returned Logger is usable anyway with the
+// defaults in place of them.
+func NewLoggerFromEnv() (*Logger, error) {
+	var errs []error
+	get := func(key string) string {
+		return strings.TrimSpace(os.Getenv(key))
+	}
+	invalid := func(key, value string, err error) {
+		errs = append(errs, fmt.Errorf("log: invalid %s %q: %w", key, value, err))
+	}
+	parseBool := func(key string) bool {
+		v := get(key)
+		if len(v) == 0 {
+			return false
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid(key, v, errors.New("not a boolean"))
+		}
+		return b
+	}
+
+	level := LogLevelDefault
+	if v := get(EnvLogLevel); len(v) != 0 {
+		if err := level.Set(v); err != nil {
+			invalid(EnvLogLevel, v, errors.New("unknown level"))
+		}
+	}
+
+	var writer io.Writer = os.Stdout
+	switch v := get(EnvLogOutput); v {
+	case "", "stdout":
+	case "stderr":
+		writer = os.Stderr
+	default:
+		w, err := NewReopenWriter(v)
+		if err != nil {
+			invalid(EnvLogOutput, v, err)
+		} else {
+			writer = w
+		}
+	}
+
+	color := parseBool(EnvLogColor)
+	timeFormat, err := parseTimeFormat(get(EnvLogTimeFormat))
+	if err != nil {
+		invalid(EnvLogTimeFormat, get(EnvLogTimeFormat), err)
+	}
+
+	formatter, err := newFormatter(get(EnvLogFormat), color, timeFormat)
+	if err != nil {
+		invalid(EnvLogFormat, get(EnvLogFormat), err)
+		formatter, _ = newFormatter("", color, timeFormat)
+	}
+
+	l := NewLogger(writer, level, CallPathDefault)
+	l.SetFormatter(formatter)
+	if parseBool(EnvLogCaller) {
+		l.EnableCaller()
+	}
+	return l, errors.Join(errs...)
+}
+
+// parseTimeFormat returns the layout of a time format, which is either a
+// layout or one of the names "RFC3339" and "RFC3339Nano"
+func parseTimeFormat(format string) (string, error) {
+	switch {
+	case strings.EqualFold(format, "RFC3339"):
+		return time.RFC3339, nil
+	case strings.EqualFold(format, "RFC3339Nano"):
+		return time.RFC3339Nano, nil
+	case len(format) != 0 && time.Unix(0, 0).Format(format) == format:
+		// NOTE: a layout without any element would write a constant
+		return "", errors.New("no time element in layout")
+	}
+	return format, nil
+}
+
+// newFormatter returns a formatter by its case insensitive name, the empty
+// name means text
+func newFormatter(name string, color bool, timeFormat string) (Formatter, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return &TextFormatter{Color: color, TimestampFormat: timeFormat}, nil
+	case "json":
+		return &JSONFormatter{TimestampFormat: timeFormat}, nil
+	case "logfmt":
+		return &LogfmtFormatter{TimestampFormat: timeFormat}, nil
+	}
+	return nil, errors.New("unknown format")
+}
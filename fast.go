@@ -14,7 +14,10 @@
 
 package log
 
-import "os"
+import (
+	"fmt"
+	"os"
+)
 
 func init() {
 	NewDefaultLogger()
@@ -22,12 +25,15 @@ func init() {
 
 var fastlogger *Logger
 
-// NewDefaultLogger returns a global instance of Logger with default configurations
+// NewDefaultLogger returns a global instance of Logger with default
+// configurations, which could be overridden by the environment, see
+// NewLoggerFromEnv. Invalid environment variables are reported to stderr.
 func NewDefaultLogger() *Logger {
-	fastlogger = NewLogger(os.Stdout, LogLevelDefault, CallPathDefault)
-	fastlogger.SetFormatter(&TextFormatter{
-		Color: false,
-	})
+	l, err := NewLoggerFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fastlogger = l
 	return fastlogger
 }
 
@@ -42,3 +48,18 @@ func SetLevel(lv string) {
 func SetColor(enabled bool) {
 	fastlogger.SetColor(enabled)
 }
+
+// SetVModule overrides the level of call sites of the default logger by
+// files, see Logger.SetVModule
+func SetVModule(spec string) error {
+	return fastlogger.SetVModule(spec)
+}
+
+// V returns a Verbose of the default logger, see Logger.V
+func V(level int) Verbose {
+	return fastlogger.V(level)
+}
+
+func SetVerbosity(v int) {
+	fastlogger.SetVerbosity(v)
+}
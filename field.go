@@ -0,0 +1,308 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+type fieldType uint8
+
+const (
+	anyType fieldType = iota
+	stringType
+	intType
+	uintType
+	boolType
+	floatType
+	durationType
+	timeType
+	errorType
+)
+
+// Field is a typed key-value pair, unlike Context it is encoded without
+// boxing the value into an interface, e.g.
+//
+//	logger.Infow("request served", log.String("path", path), log.Int("status", 200))
+type Field struct {
+	Key string
+
+	typ   fieldType
+	num   int64
+	str   string
+	iface any
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, typ: stringType, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, typ: intType, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, typ: intType, num: value}
+}
+
+func Uint(key string, value uint) Field {
+	return Field{Key: key, typ: uintType, num: int64(value)}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, typ: uintType, num: int64(value)}
+}
+
+func Bool(key string, value bool) Field {
+	var num int64
+	if value {
+		num = 1
+	}
+	return Field{Key: key, typ: boolType, num: num}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, typ: floatType, num: int64(math.Float64bits(value))}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, typ: durationType, num: int64(value)}
+}
+
+// Time keeps the location of value, times which could not be represented
+// by UnixNano are stored as Any
+func Time(key string, value time.Time) Field {
+	if y := value.Year(); y < 1678 || y > 2261 {
+		return Any(key, value)
+	}
+	return Field{Key: key, typ: timeType, num: value.UnixNano(), iface: value.Location()}
+}
+
+// Err returns a field with key "error"
+func Err(err error) Field {
+	return Field{Key: "error", typ: errorType, iface: err}
+}
+
+// Any falls back to the encoding of Context values
+func Any(key string, value any) Field {
+	return Field{Key: key, typ: anyType, iface: value}
+}
+
+// value returns the field as a Context value
+func (f Field) value() any {
+	switch f.typ {
+	case stringType:
+		return f.str
+	case intType:
+		return f.num
+	case uintType:
+		return uint64(f.num)
+	case boolType:
+		return f.num == 1
+	case floatType:
+		return math.Float64frombits(uint64(f.num))
+	case durationType:
+		return time.Duration(f.num)
+	case timeType:
+		return f.time()
+	}
+	return f.iface
+}
+
+func (f Field) time() time.Time {
+	t := time.Unix(0, f.num)
+	if loc, ok := f.iface.(*time.Location); ok {
+		t = t.In(loc)
+	}
+	return t
+}
+
+func (f Field) appendText(dst []byte) []byte {
+	switch f.typ {
+	case stringType:
+		return appendTextString(dst, f.str)
+	case intType:
+		return strconv.AppendInt(dst, f.num, 10)
+	case uintType:
+		return strconv.AppendUint(dst, uint64(f.num), 10)
+	case boolType:
+		return strconv.AppendBool(dst, f.num == 1)
+	case floatType:
+		return strconv.AppendFloat(dst, math.Float64frombits(uint64(f.num)), 'g', -1, 64)
+	case durationType:
+		return appendDuration(dst, time.Duration(f.num))
+	case timeType:
+		return f.time().AppendFormat(dst, time.RFC3339Nano)
+	}
+	return appendTextValue(dst, f.iface)
+}
+
+func (f Field) appendJSON(dst []byte) []byte {
+	switch f.typ {
+	case stringType:
+		return appendJSONString(dst, f.str)
+	case intType:
+		return strconv.AppendInt(dst, f.num, 10)
+	case uintType:
+		return strconv.AppendUint(dst, uint64(f.num), 10)
+	case boolType:
+		return strconv.AppendBool(dst, f.num == 1)
+	case floatType:
+		return appendJSONFloat(dst, math.Float64frombits(uint64(f.num)), 64)
+	case durationType:
+		dst = append(dst, '"')
+		dst = appendDuration(dst, time.Duration(f.num))
+		return append(dst, '"')
+	case timeType:
+		dst = append(dst, '"')
+		dst = f.time().AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"')
+	}
+	return appendJSONValue(dst, f.iface)
+}
+
+func appendTextFields(dst []byte, fields []Field) []byte {
+	for i := range fields {
+		if isReservedKey(fields[i].Key) {
+			dst = append(dst, fieldsPrefix...)
+		}
+		dst = appendTextString(dst, fields[i].Key)
+		dst = append(dst, '=')
+		dst = fields[i].appendText(dst)
+		dst = append(dst, ' ')
+	}
+	return dst
+}
+
+func appendJSONFields(dst []byte, fields []Field) []byte {
+	for i := range fields {
+		dst = append(dst, ',', '"')
+		if isReservedKey(fields[i].Key) {
+			dst = append(dst, fieldsPrefix...)
+		}
+		dst = appendJSONEscaped(dst, fields[i].Key)
+		dst = append(dst, '"', ':')
+		dst = fields[i].appendJSON(dst)
+	}
+	return dst
+}
+
+// appendDuration appends d in the format of time.Duration.String
+// without allocating
+func appendDuration(dst []byte, d time.Duration) []byte {
+	var buf [32]byte
+	w := len(buf)
+
+	u := uint64(d)
+	neg := d < 0
+	if neg {
+		u = -u
+	}
+
+	if u < uint64(time.Second) {
+		// less than one second, use a smaller unit such as 1.2ms
+		var prec int
+		w--
+		buf[w] = 's'
+		w--
+		switch {
+		case u == 0:
+			return append(dst, '0', 's')
+		case u < uint64(time.Microsecond):
+			prec = 0
+			buf[w] = 'n'
+		case u < uint64(time.Millisecond):
+			prec = 3
+			// U+00B5 'µ' micro sign == 0xC2 0xB5
+			w--
+			copy(buf[w:], "µ")
+		default:
+			prec = 6
+			buf[w] = 'm'
+		}
+		w, u = fmtFrac(buf[:w], u, prec)
+		w = fmtInt(buf[:w], u)
+	} else {
+		w--
+		buf[w] = 's'
+
+		w, u = fmtFrac(buf[:w], u, 9)
+
+		// u is now integer seconds
+		w = fmtInt(buf[:w], u%60)
+		u /= 60
+
+		// u is now integer minutes
+		if u > 0 {
+			w--
+			buf[w] = 'm'
+			w = fmtInt(buf[:w], u%60)
+			u /= 60
+
+			// u is now integer hours
+			if u > 0 {
+				w--
+				buf[w] = 'h'
+				w = fmtInt(buf[:w], u)
+			}
+		}
+	}
+
+	if neg {
+		w--
+		buf[w] = '-'
+	}
+
+	return append(dst, buf[w:]...)
+}
+
+// fmtFrac formats the fraction of v/10**prec (e.g., ".12345") into the
+// tail of buf, omitting trailing zeros, it is copied from package time
+func fmtFrac(buf []byte, v uint64, prec int) (nw int, nv uint64) {
+	w := len(buf)
+	print := false
+	for i := 0; i < prec; i++ {
+		digit := v % 10
+		print = print || digit != 0
+		if print {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v /= 10
+	}
+	if print {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+// fmtInt formats v into the tail of buf, it is copied from package time
+func fmtInt(buf []byte, v uint64) int {
+	w := len(buf)
+	if v == 0 {
+		w--
+		buf[w] = '0'
+	} else {
+		for v > 0 {
+			w--
+			buf[w] = byte(v%10) + '0'
+			v /= 10
+		}
+	}
+	return w
+}
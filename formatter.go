@@ -14,6 +14,12 @@
 
 package log
 
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Formatter will decide how logs are printed
 // Default consist of:
 // * TextFormatter, print as "application=foo cluster=production msg=deployment not found"
@@ -21,54 +27,134 @@ package log
 type Formatter interface {
 	// Print(ctx Context, fields *Fields) string
 
+	// SetColor colors entries by their levels, it has no effect on machine
+	// readable formats such as JSON, logfmt, syslog and journald
 	SetColor(color bool)
 	Render(e *LogEntry)
 }
 
-/*
+// colorCloner is implemented by formatters which could be copied with
+// another color, so that the color is changed without a data race
+type colorCloner interface {
+	withColor(color bool) Formatter
+}
+
+// contextEncoder is implemented by formatters which are able to encode
+// the context of a child logger once, the result is reused by every entry
+type contextEncoder interface {
+	encodeContext(dst []byte, ctx Context) []byte
+}
+
+// JSONFormatter renders every entry as one JSON object per line, context
+// fields are written in key order after the builtin keys
 type JSONFormatter struct {
 	Color bool
+	// TimestampFormat is time.RFC3339Nano if it is empty
+	TimestampFormat string
 }
 
-func (t *JSONFormatter) SetColor(color bool) {
-	t.Color = color
+func (j *JSONFormatter) SetColor(color bool) {
+	j.Color = color
 }
 
-func (t *JSONFormatter) Print(ctx Context, fields *Fields) string {
-	if ctx == nil {
-		ctx = make(map[string]any)
-	}
+func (j *JSONFormatter) withColor(color bool) Formatter {
+	f := *j
+	f.Color = color
+	return &f
+}
 
-	ctx["msg"] = fields.Msg
+// keys written by the formatters themselves, context keys with the same
+// name are prefixed by "fields."
+const (
+	keyTime   = "time"
+	keyLevel  = "level"
+	keyMsg    = "msg"
+	keyCaller = "caller"
+	keyFunc   = "func"
+
+	fieldsPrefix = "fields."
+)
+
+func isReservedKey(k string) bool {
+	switch k {
+	case keyTime, keyLevel, keyMsg, keyCaller, keyFunc:
+		return true
+	}
+	return false
+}
 
-	fields.Context = ctx
-	data, err := json.Marshal(fields)
-	if err != nil {
-		panic(err)
+func (j *JSONFormatter) Render(e *LogEntry) {
+	format := j.TimestampFormat
+	if len(format) == 0 {
+		format = time.RFC3339Nano
+	}
+	e.SetTimestamp(format)
+
+	e.buf = append(e.buf, `{"time":`...)
+	e.buf = appendJSONString(e.buf, bytesToString(e.timestamp))
+	e.buf = append(e.buf, `,"level":`...)
+	e.buf = appendJSONString(e.buf, e.level)
+	e.buf = append(e.buf, `,"msg":`...)
+	e.buf = appendJSONString(e.buf, strings.TrimSuffix(e.msg, "\n"))
+
+	if len(e.file) != 0 {
+		e.buf = append(e.buf, `,"caller":"`...)
+		e.buf = appendJSONEscaped(e.buf, e.file)
+		e.buf = append(e.buf, ':')
+		e.buf = strconv.AppendInt(e.buf, int64(e.line), 10)
+		e.buf = append(e.buf, `","func":`...)
+		e.buf = appendJSONString(e.buf, e.funcname)
 	}
 
-	return string(data)
+	e.buf = append(e.buf, e.encoded...)
+	e.buf = appendJSONContext(e.buf, e.context)
+	e.buf = appendJSONFields(e.buf, e.fields)
+
+	e.buf = append(e.buf, '}', '\n')
+}
+
+func (j *JSONFormatter) encodeContext(dst []byte, ctx Context) []byte {
+	return appendJSONContext(dst, ctx)
+}
+
+// appendJSONContext appends ctx as sorted object members, each of them
+// is preceded by a comma
+func appendJSONContext(dst []byte, ctx Context) []byte {
+	for _, k := range ctx.sortedKeys() {
+		dst = append(dst, ',')
+		if isReservedKey(k) {
+			dst = appendJSONString(dst, fieldsPrefix+k)
+		} else {
+			dst = appendJSONString(dst, k)
+		}
+		dst = append(dst, ':')
+		dst = appendJSONValue(dst, ctx[k])
+	}
+	return dst
 }
-*/
 
 type TextFormatter struct {
 	Color           bool
 	TimestampFormat string
-	DynamicLevel    bool
+
+	// Deprecated: DynamicLevel has no effect, the level is controlled by
+	// signals with Logger.WatchLevelSignals
+	DynamicLevel bool
 }
 
 func (t *TextFormatter) SetColor(color bool) {
 	t.Color = color
 }
 
+func (t *TextFormatter) withColor(color bool) Formatter {
+	f := *t
+	f.Color = color
+	return &f
+}
+
 func (t *TextFormatter) Render(e *LogEntry) {
 	e.SetColor(t.Color).SetTimestamp(t.TimestampFormat)
 
-	if t.DynamicLevel {
-		// TODO: add signal SIGUSR handling
-		todo()
-	}
-
 	if e.newline {
 		e.SetNewline()
 	}
@@ -76,6 +162,10 @@ func (t *TextFormatter) Render(e *LogEntry) {
 	e.Render()
 }
 
+func (t *TextFormatter) encodeContext(dst []byte, ctx Context) []byte {
+	return appendTextContext(dst, ctx)
+}
+
 /*
 func (t *TextFormatter) Print(ctx Context, fields *Fields) string {
 	if ctx == nil {
@@ -0,0 +1,289 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_JSONFormatter(t *testing.T) {
+	e := &LogEntry{}
+	e.SetLevel(EnvLogLevelInfo).SetMsg("quote \" backslash \\ newline \n tab \t nul \x00 bad \xff\n")
+	e.SetCaller("log_test.go", "github.com/mlycore/log.Test", 42)
+	e.WithContext(Context{
+		"msg":     "collides",
+		"status":  200,
+		"ratio":   math.NaN(),
+		"err":     errors.New("boom"),
+		"ch":      make(chan int),
+		"panicky": panickyMarshaler{},
+		"nilerr":  error(nil),
+		"payload": map[string]int{"a": 1},
+	})
+
+	f := &JSONFormatter{}
+	f.Render(e)
+
+	out := e.Bytes()
+	if !bytes.HasSuffix(out, []byte("}\n")) {
+		t.Fatalf("output should be one line: %q", out)
+	}
+
+	m := map[string]any{}
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatalf("invalid json %q: %s", out, err)
+	}
+
+	expected := map[string]any{
+		"level":      "INFO",
+		"msg":        "quote \" backslash \\ newline \n tab \t nul \x00 bad �",
+		"caller":     "log_test.go:42",
+		"func":       "github.com/mlycore/log.Test",
+		"fields.msg": "collides",
+		"status":     float64(200),
+		"ratio":      "NaN",
+		"err":        "boom",
+		"nilerr":     nil,
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("key %s: expected %#v, got %#v", k, v, m[k])
+		}
+	}
+	if _, ok := m["ch"].(string); !ok {
+		t.Errorf("unmarshalable value should fall back to a string, got %#v", m["ch"])
+	}
+	if _, ok := m["panicky"].(string); !ok {
+		t.Errorf("panicking MarshalJSON should fall back to a string, got %#v", m["panicky"])
+	}
+	if p, ok := m["payload"].(map[string]any); !ok || p["a"] != float64(1) {
+		t.Errorf("unexpected payload %#v", m["payload"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, m["time"].(string)); err != nil {
+		t.Errorf("time should default to RFC3339Nano: %v", err)
+	}
+}
+
+type panickyMarshaler struct{}
+
+func (panickyMarshaler) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+func Test_JSONFormatterLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+	logger.SetFormatter(&JSONFormatter{})
+
+	logger.Infof("hello %s\n", "world")
+	logger.Warnln("second line")
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	for _, line := range lines {
+		if !json.Valid(line) {
+			t.Errorf("invalid json line %q", line)
+		}
+	}
+}
+
+func Test_TextFormatterContext(t *testing.T) {
+	e := &LogEntry{}
+	e.SetLevel(EnvLogLevelInfo).SetMsg("hello")
+	e.WithContext(Context{
+		"b":     "has space",
+		"a":     1,
+		"msg":   "collides",
+		"level": "collides",
+		"empty": "",
+		"eq":    "a=b",
+		"quote": `say "hi"`,
+		"err":   errors.New("not found"),
+	})
+
+	f := &TextFormatter{}
+	f.Render(e)
+
+	expected := `[INFO] a=1 b="has space" empty="" eq="a=b" err="not found" fields.level=collides fields.msg=collides quote="say \"hi\"" msg=hello`
+	if !bytes.HasSuffix(e.Bytes(), []byte(expected)) {
+		t.Fatalf("expected suffix %q, got %q", expected, e.Bytes())
+	}
+}
+
+func Test_LogfmtFormatter(t *testing.T) {
+	e := &LogEntry{}
+	e.SetLevel(EnvLogLevelInfo).SetMsg("say \"hi\" \\ nul \x00 bad \xff\n")
+	e.SetTime(time.Date(2024, 3, 8, 16, 30, 0, 0, time.UTC))
+	e.SetCaller("log_test.go", "github.com/mlycore/log.Test", 42)
+	e.WithContext(Context{
+		"msg":     "collides",
+		"empty":   "",
+		"nil":     nil,
+		"null":    "null",
+		"bad key": 1,
+		"unicode": "héllo",
+		"err":     errors.New("no such file"),
+	})
+	e.WithFields([]Field{Int("n", 3), Err(errors.New("x y")), String("s", "a=b")})
+
+	f := &LogfmtFormatter{TimestampFormat: time.RFC3339}
+	f.Render(e)
+
+	expected := `time=2024-03-08T16:30:00Z level=info msg="say \"hi\" \\ nul \u0000 bad \ufffd" ` +
+		`caller=log_test.go:42 func=github.com/mlycore/log.Test ` +
+		`bad_key=1 empty="" err="no such file" fields.msg=collides nil=null null="null" unicode=héllo ` +
+		`n=3 error="x y" s="a=b"` + "\n"
+	if got := string(e.Bytes()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func Test_LogfmtFormatterLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+	logger.SetFormatter(&LogfmtFormatter{})
+	logger.With("shard", 3).Warnln("")
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, ` level=warn msg="" shard=3`+"\n") {
+		t.Fatalf("unexpected output %q", line)
+	}
+	ts := strings.TrimPrefix(strings.Fields(line)[0], "time=")
+	if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_PatternFormatter(t *testing.T) {
+	f, err := NewPatternFormatter("%d{2006-01-02T15:04:05} %-5p|%5p [%c] %F:%L %M %.6X{trace} %X{user}/%X{n} %m%%%n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &LogEntry{}
+	e.SetLevel(EnvLogLevelInfo).SetMsg("done\n").SetTime(time.Date(2024, 3, 8, 16, 30, 0, 0, time.UTC))
+	e.SetCaller("main.go", "main.main", 12)
+	e.name = "db.pool"
+	e.WithContext(Context{"trace": "0123456789", "user": "alice"})
+	e.WithFields([]Field{Int("n", 3)})
+	f.Render(e)
+
+	expected := "2024-03-08T16:30:00 INFO | INFO [db.pool] main.go:12 main.main 456789 alice/3 done%\n"
+	if got := string(e.Bytes()); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	for _, pattern := range []string{"%q", "%d{2006", "%m%", "%X", "%m{x}", "%-.p", "%256m", "%.99999999999999999999m"} {
+		if _, err := NewPatternFormatter(pattern); err == nil {
+			t.Fatalf("expected an error for %q", pattern)
+		}
+	}
+	if _, err := NewPatternFormatter("%255m"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_PatternFormatterLogger(t *testing.T) {
+	f, err := NewPatternFormatter("%-5p [%c] %F %X{shard} %m%n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	saved := fastlogger
+	defer func() {
+		fastlogger = saved
+		registry.Lock()
+		delete(registry.loggers, "pattern.test")
+		registry.Unlock()
+	}()
+	fastlogger = NewLogger(buf, LogLevelInfo, 0)
+	fastlogger.SetFormatter(f)
+
+	logger := Named("pattern.test")
+	logger.With("shard", 3).Warnln("w")
+	fastlogger.SetColor(true)
+	logger.Errorln("e")
+
+	expected := "WARN  [pattern.test] ? 3 w\n\033[31mERROR [pattern.test] ?  e\033[0m\n"
+	if got := buf.String(); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func Test_SyslogFormatter(t *testing.T) {
+	newEntry := func() *LogEntry {
+		e := &LogEntry{}
+		e.SetLevel(EnvLogLevelWarn).SetMsg("disk full\n")
+		e.SetTime(time.Date(2024, 3, 8, 16, 30, 0, 123456000, time.UTC))
+		e.SetCaller("main.go", "main.main", 12)
+		e.WithContext(Context{"path": `C:\a "b" [c]`, "msg": "collides", "bad key": 1})
+		e.WithFields([]Field{Int("n", 3)})
+		return e
+	}
+	pid := strconv.Itoa(os.Getpid())
+
+	e := newEntry()
+	f := &SyslogFormatter{Facility: FacilityLocal0, Hostname: "host", AppName: "app"}
+	f.Render(e)
+	expected := "<132>1 2024-03-08T16:30:00.123456Z host app " + pid + ` - ` +
+		`[ctx@32473 caller="main.go:12" func="main.main" bad_key="1" fields.msg="collides" path="C:\\a \"b\" [c\]" n="3"] disk full` + "\n"
+	if got := string(e.Bytes()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	e = newEntry()
+	f = &SyslogFormatter{Protocol: RFC3164, Hostname: "host", AppName: "my app"}
+	f.Render(e)
+	expected = "<12>Mar  8 16:30:00 host my_app[" + pid + `]: disk full caller=main.go:12 func=main.main ` +
+		`bad_key=1 fields.msg=collides path="C:\\a \"b\" [c]" n=3` + "\n"
+	if got := string(e.Bytes()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	e = &LogEntry{}
+	e.SetLevel(EnvLogLevelError).SetMsg("plain")
+	(&SyslogFormatter{Hostname: "host", AppName: "app", MsgID: "ID47"}).Render(e)
+	if got := string(e.Bytes()); !strings.HasPrefix(got, "<11>1 ") || !strings.HasSuffix(got, " host app "+pid+" ID47 - plain\n") {
+		t.Fatalf("unexpected output %s", got)
+	}
+}
+
+func Test_JournalFormatter(t *testing.T) {
+	e := &LogEntry{}
+	e.SetLevel(EnvLogLevelError).SetMsg("two\nlines\n")
+	e.SetCaller("main.go", "main.main", 12)
+	e.WithContext(Context{"user_id": 7, "message": "collides", "_pid": 1, "1st": "x", "a.b": nil})
+	e.WithFields([]Field{String("trace-id", "abc")})
+
+	f := &JournalFormatter{AppName: "app"}
+	f.Render(e)
+
+	expected := "PRIORITY=3\nSYSLOG_IDENTIFIER=app\nMESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n" +
+		"CODE_FILE=main.go\nCODE_LINE=12\nCODE_FUNC=main.main\n" +
+		"FIELDS_1ST=x\nPID=1\nA_B=\nFIELDS_MESSAGE=collides\nUSER_ID=7\nTRACE_ID=abc\n"
+	if got := string(e.Bytes()); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
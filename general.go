@@ -14,7 +14,10 @@
 
 package log
 
-import "io"
+import (
+	"io"
+	"sync"
+)
 
 // Log is one glocal logger which can be used in any packages
 // e.g.
@@ -22,35 +25,29 @@ import "io"
 // var Log = NewLogger(os.Stdout, INFO)
 // 2.
 // var logger = NewLogger(os.Stdout, INFO, CallPath)
-// 3.
-//
-//	var logger = &Logger{
-//		Writer:   os.Stdout,
-//		Level:    INFO,
-//		CallPath: 3,
-//		Color:    true,
-//	}
 //
+// NOTE: a Logger should always be created by NewLogger, since the level
+// gate and the entry pool are initialized there
 
 // TODO: make functions meta a optional argument
-// fields.File, fields.Func, fields.Line = getFuncInfo(l.CallPath)
+// fields.File, fields.Func, fields.Line = getFuncInfo(l.GetCallPath())
 
 // NewLogger returns a instance of Logger
-func NewLogger(writer io.Writer, level, caller int) *Logger {
-	return &Logger{
-		Writer:   writer,
-		Level:    level,
-		LevelStr: getLogLevel(level),
-		CallPath: caller,
-		// formatter: &TextFormatter{Color: false},
-
-		epool: epool,
+func NewLogger(writer io.Writer, level Level, caller int) *Logger {
+	l := &Logger{
+		epool: &epool,
 	}
+	l.callPath.Store(int32(caller))
+	l.level.Store(int32(level))
+	l.SetWriter(writer)
+	l.SetFormatter(&TextFormatter{Color: false})
+	return l
 }
 
 var (
-	// LogLevelMap is log level ma
-	LogLevelMap = map[int]string{
+	// LogLevelMap is log level map of the built-in levels, it is a frozen
+	// snapshot which is not updated by RegisterLevel, see LookupLevel
+	LogLevelMap = map[Level]string{
 		LogLevelUnspecified: "UNSPECIFIED",
 		LogLevelTrace:       "TRACE",
 		LogLevelDebug:       "DEBUG",
@@ -69,28 +66,34 @@ type LoggerIface interface {
 }
 */
 
-/*
-func SetDefaultLogFile() {
-	SetLogFile(DefaultLogFile)
+// SetDefaultLogFile makes the default logger write to DefaultLogFile
+func SetDefaultLogFile(opts RotateOptions) error {
+	return SetLogFile(DefaultLogFile, opts)
 }
 
-func SetLogFile(path string) {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0666)
+var (
+	logFileMu sync.Mutex
+	logFile   *RotateWriter
+)
+
+// SetLogFile makes the default logger write to a RotateWriter of path,
+// the file opened by a previous call is closed
+func SetLogFile(path string, opts RotateOptions) error {
+	w, err := NewRotateWriter(path, opts)
 	if err != nil {
-		f, err := os.Create(path)
-		if err != nil {
-			panic(err)
-		} else {
-			file = f
-		}
-	} else {
-		file = f
+		return err
 	}
 
-	logger.Writer = file
-	println(file.Name())
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+
+	fastlogger.SetWriter(w)
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+	logFile = w
+	return nil
 }
-*/
 
 // TODO: need refactor
 /*
@@ -17,8 +17,10 @@ package log
 import (
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
+	"unsafe"
 )
 
 func getShortFileName(file string) string {
@@ -35,16 +37,37 @@ func getTimestamp() string {
 	return time.Now().Format(TimeFormatDefault)
 }
 
-func getLogLevel(level int) string {
-	return LogLevelMap[level]
+func getLogLevel(level Level) string {
+	if def, ok := levels.Load().bySeverity[level]; ok {
+		return def.Name
+	}
+	if level == LogLevelUnspecified {
+		return levelUnspecifiedName
+	}
+	return "LEVEL(" + strconv.Itoa(int(level)) + ")"
 }
 
+// getFuncInfo returns the caller at the depth of callpath, with the same
+// meaning of the argument of runtime.Caller
 func getFuncInfo(callpath int) (file, funcname string, line int) {
-	var pc uintptr
-	pc, file, line, _ = runtime.Caller(callpath)
-	funcname = runtime.FuncForPC(pc).Name()
-	file = getShortFileName(file)
-	return
+	var pcs [1]uintptr
+	// NOTE: runtime.Callers counts itself as the frame 0
+	if runtime.Callers(callpath+1, pcs[:]) == 0 {
+		return
+	}
+	// NOTE: frames resolve inlined functions which FuncForPC does not
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	return getShortFileName(frame.File), getShortFileName(frame.Function), frame.Line
+}
+
+// getFuncInfoFromPC returns the caller of a program counter such as the
+// one of runtime.Callers
+func getFuncInfoFromPC(pc uintptr) (file, funcname string, line int) {
+	if pc == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return getShortFileName(frame.File), getShortFileName(frame.Function), frame.Line
 }
 
 func formattedMessage(format string, v ...interface{}) (formatString string) {
@@ -55,3 +78,9 @@ func formattedMessage(format string, v ...interface{}) (formatString string) {
 	}
 	return
 }
+
+// bytesToString converts b to a string without copying, b must not be
+// modified while the string is in use
+func bytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
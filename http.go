@@ -0,0 +1,197 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LevelHandler returns an http.Handler which reads and changes the levels
+// of the default logger and the registered ones:
+//
+//	GET  /?logger=db          reports the level of one logger, or all of them without logger
+//	PUT  {"logger": "db", "level": "debug", "ttl": "10m"}
+//
+// PUT and POST accept either a JSON body or form values of the same keys,
+// the empty logger refers to the default logger. With a ttl the level is
+// reverted after that duration, so a forgotten DEBUG will not flood disks.
+func LevelHandler() http.Handler {
+	return &levelHandler{reverts: map[*Logger]*levelRevert{}}
+}
+
+type levelHandler struct {
+	mu      sync.Mutex
+	reverts map[*Logger]*levelRevert
+}
+
+// levelRevert restores level of a logger when timer fires, the prefix of a
+// named logger which had no level of its own is reset instead if inherit
+// is set
+type levelRevert struct {
+	level   Level
+	inherit bool
+	at      time.Time
+	timer   *time.Timer
+}
+
+type levelRequest struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+type levelState struct {
+	Logger      string     `json:"logger"`
+	Level       Level      `json:"level"`
+	RevertLevel Level      `json:"revert_level,omitempty"`
+	RevertAt    *time.Time `json:"revert_at,omitempty"`
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodPut, http.MethodPost:
+		h.put(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+	}
+}
+
+func (h *levelHandler) get(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("logger"); len(name) != 0 {
+		l, ok := Lookup(name)
+		if !ok {
+			httpError(w, http.StatusNotFound, fmt.Errorf("logger %q is not registered", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, h.state(name, l))
+		return
+	}
+
+	states := []levelState{}
+	for _, name := range registeredNames() {
+		if l, ok := Lookup(name); ok {
+			states = append(states, h.state(name, l))
+		}
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (h *levelHandler) put(w http.ResponseWriter, r *http.Request) {
+	req := levelRequest{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		req.Logger = r.Form.Get("logger")
+		req.Level = r.Form.Get("level")
+		req.TTL = r.Form.Get("ttl")
+	}
+
+	l, ok := Lookup(req.Logger)
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("logger %q is not registered", req.Logger))
+		return
+	}
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	var ttl time.Duration
+	if len(req.TTL) != 0 {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl %q", req.TTL))
+			return
+		}
+		ttl = d
+	}
+
+	h.setLevel(l, level, ttl)
+	writeJSON(w, http.StatusOK, h.state(req.Logger, l))
+}
+
+// setLevel changes the level of l, a pending revert is replaced and keeps
+// the level before the first temporary change
+func (h *levelHandler) setLevel(l *Logger, level Level, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	original, inherit := l.GetLevel(), l.lvl != nil && !hasLevelFor(l.name)
+	if rv, ok := h.reverts[l]; ok {
+		rv.timer.Stop()
+		original, inherit = rv.level, rv.inherit
+		delete(h.reverts, l)
+	}
+	l.SetLevel(level)
+
+	if ttl == 0 {
+		return
+	}
+	rv := &levelRevert{level: original, inherit: inherit, at: time.Now().Add(ttl)}
+	rv.timer = time.AfterFunc(ttl, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		// NOTE: the revert could be replaced while the timer was firing
+		if h.reverts[l] != rv {
+			return
+		}
+		delete(h.reverts, l)
+		if rv.inherit {
+			ResetLevelFor(l.name)
+		} else {
+			l.SetLevel(rv.level)
+		}
+		l.notice(fmt.Sprintf("log level reverted to %s", l.GetLevel()))
+	})
+	h.reverts[l] = rv
+}
+
+func (h *levelHandler) state(name string, l *Logger) levelState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := levelState{Logger: name, Level: l.GetLevel()}
+	if rv, ok := h.reverts[l]; ok {
+		at := rv.at
+		s.RevertLevel = rv.level
+		s.RevertAt = &at
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
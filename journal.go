@@ -0,0 +1,189 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// DefaultJournalSocket is the socket of the native protocol of journald
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// journal fields written by JournalFormatter itself, context keys with the
+// same name are prefixed by "FIELDS_"
+const (
+	journalMessage    = "MESSAGE"
+	journalPriority   = "PRIORITY"
+	journalFile       = "CODE_FILE"
+	journalLine       = "CODE_LINE"
+	journalFunc       = "CODE_FUNC"
+	journalIdentifier = "SYSLOG_IDENTIFIER"
+
+	journalPrefix = "FIELDS_"
+)
+
+// JournalFormatter renders entries in the native protocol of journald, to
+// be written by a JournalWriter. The level is mapped to PRIORITY by its
+// syslog severity, see LevelDef, the caller to CODE_FILE, CODE_LINE and
+// CODE_FUNC, and context keys to upper case fields such as "user_id" to
+// USER_ID.
+type JournalFormatter struct {
+	// AppName is SYSLOG_IDENTIFIER, the name of the executable if it is empty
+	AppName string
+	Color   bool
+}
+
+func (j *JournalFormatter) SetColor(color bool) {
+	j.Color = color
+}
+
+func (j *JournalFormatter) withColor(color bool) Formatter {
+	f := *j
+	f.Color = color
+	return &f
+}
+
+func (j *JournalFormatter) Render(e *LogEntry) {
+	appName := j.AppName
+	if len(appName) == 0 {
+		appName = syslogAppName()
+	}
+
+	e.buf = append(e.buf, journalPriority+"="...)
+	e.buf = strconv.AppendInt(e.buf, int64(syslogSeverity(e)), 10)
+	e.buf = append(e.buf, '\n')
+	e.buf = appendJournalString(e.buf, journalIdentifier, appName)
+	e.buf = appendJournalString(e.buf, journalMessage, strings.TrimSuffix(e.msg, "\n"))
+
+	if len(e.file) != 0 {
+		e.buf = appendJournalString(e.buf, journalFile, e.file)
+		e.buf = append(e.buf, journalLine+"="...)
+		e.buf = strconv.AppendInt(e.buf, int64(e.line), 10)
+		e.buf = append(e.buf, '\n')
+		e.buf = appendJournalString(e.buf, journalFunc, e.funcname)
+	}
+
+	e.buf = append(e.buf, e.encoded...)
+	e.buf = appendJournalContext(e.buf, e.context)
+	var eq int
+	for i := range e.fields {
+		e.buf, eq = appendJournalKey(e.buf, e.fields[i].Key)
+		e.buf = endJournalValue(e.fields[i].appendJournal(e.buf), eq)
+	}
+}
+
+func (j *JournalFormatter) encodeContext(dst []byte, ctx Context) []byte {
+	return appendJournalContext(dst, ctx)
+}
+
+// appendJournalContext appends ctx as sorted journal fields
+func appendJournalContext(dst []byte, ctx Context) []byte {
+	for _, k := range ctx.sortedKeys() {
+		var eq int
+		dst, eq = appendJournalKey(dst, k)
+		dst = endJournalValue(appendJournalValue(dst, ctx[k]), eq)
+	}
+	return dst
+}
+
+func appendJournalString(dst []byte, key, value string) []byte {
+	eq := len(dst) + len(key)
+	dst = append(dst, key...)
+	dst = append(dst, '=')
+	return endJournalValue(append(dst, value...), eq)
+}
+
+// appendJournalKey appends the field name of a context key and '=', eq is
+// the index of '='. A field name consists of upper case letters, digits
+// and '_', it starts with a letter and is at most 64 bytes long.
+func appendJournalKey(dst []byte, k string) (_ []byte, eq int) {
+	start := len(dst)
+	for i := 0; i < len(k); i++ {
+		switch c := k[i]; {
+		case 'a' <= c && c <= 'z':
+			dst = append(dst, c-'a'+'A')
+		case ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9'):
+			dst = append(dst, c)
+		case len(dst) == start:
+			// NOTE: a leading '_' is dropped, since fields starting with
+			// it are trusted ones set by journald
+		default:
+			dst = append(dst, '_')
+		}
+	}
+
+	name := bytesToString(dst[start:])
+	if len(name) == 0 || ('0' <= name[0] && name[0] <= '9') || isJournalReserved(name) {
+		dst = append(dst, journalPrefix...)
+		copy(dst[start+len(journalPrefix):], dst[start:])
+		copy(dst[start:], journalPrefix)
+	}
+	if len(dst)-start > 64 {
+		dst = dst[:start+64]
+	}
+	return append(dst, '='), len(dst)
+}
+
+func isJournalReserved(name string) bool {
+	switch name {
+	case journalMessage, journalPriority, journalFile, journalLine, journalFunc, journalIdentifier:
+		return true
+	}
+	return false
+}
+
+// endJournalValue terminates the value after dst[eq], a value with
+// newlines is converted to the binary form, which is the name, a newline,
+// the length as a little endian uint64 and the value
+func endJournalValue(dst []byte, eq int) []byte {
+	value := dst[eq+1:]
+	if bytes.IndexByte(value, '\n') < 0 {
+		return append(dst, '\n')
+	}
+
+	n := len(value)
+	dst = append(dst, 0, 0, 0, 0, 0, 0, 0, 0)
+	copy(dst[eq+9:], dst[eq+1:eq+1+n])
+	dst[eq] = '\n'
+	binary.LittleEndian.PutUint64(dst[eq+1:], uint64(n))
+	return append(dst, '\n')
+}
+
+// appendJournalValue appends v as the raw text of a journal field, nil is
+// written as an empty value
+func appendJournalValue(dst []byte, v any) []byte {
+	if v == nil {
+		return dst
+	}
+	return appendScalar(dst, v, appendJournalText)
+}
+
+// appendJournalText appends s as it is, journal fields are never escaped
+func appendJournalText(dst []byte, s string) []byte {
+	return append(dst, s...)
+}
+
+func (f Field) appendJournal(dst []byte) []byte {
+	switch f.typ {
+	case stringType:
+		return append(dst, f.str...)
+	case intType, uintType, boolType, floatType, durationType, timeType:
+		return f.appendText(dst)
+	}
+	return appendJournalValue(dst, f.iface)
+}
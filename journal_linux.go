@@ -0,0 +1,152 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// sysMemfdCreate is the number of memfd_create, which syscall lacks on
+// some architectures, zero means a temporary file is used instead
+var sysMemfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}[runtime.GOARCH]
+
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fAddSeals       = 0x409
+	// fSeals are F_SEAL_SEAL, F_SEAL_SHRINK, F_SEAL_GROW and F_SEAL_WRITE,
+	// journald maps a sealed memfd instead of copying it
+	fSeals = 0x1 | 0x2 | 0x4 | 0x8
+)
+
+// JournalWriter sends every write as an entry of journald, usually rendered
+// by JournalFormatter. An entry too large for a datagram is written to a
+// memfd, or an unlinked file in /dev/shm, whose descriptor is passed
+// instead. It is safe for concurrent use.
+type JournalWriter struct {
+	addr *net.UnixAddr
+	conn *net.UnixConn
+}
+
+// NewJournalWriter returns a writer to the journal socket at path,
+// DefaultJournalSocket is used if path is empty
+func NewJournalWriter(path string) (*JournalWriter, error) {
+	if len(path) == 0 {
+		path = DefaultJournalSocket
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("log: journal is not available: %w", err)
+	}
+
+	// NOTE: the socket is not connected, so that a restart of journald,
+	// which recreates its socket, does not break it
+	autobind, err := net.ResolveUnixAddr("unixgram", "")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", autobind)
+	if err != nil {
+		return nil, err
+	}
+	return &JournalWriter{
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"},
+		conn: conn,
+	}, nil
+}
+
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	_, _, err := w.conn.WriteMsgUnix(p, nil, w.addr)
+	if err == nil {
+		return len(p), nil
+	}
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return 0, err
+	}
+
+	f, err := sealedFile(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, _, err := w.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), w.addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sealedFile returns a sealed memfd, or an unlinked file in /dev/shm,
+// which holds p
+func sealedFile(p []byte) (*os.File, error) {
+	if f, err := memfd(p); err == nil {
+		return f, nil
+	}
+
+	f, err := os.CreateTemp("/dev/shm", "journal.")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func memfd(p []byte) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, syscall.ENOSYS
+	}
+	name := []byte("journal\x00")
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(&name[0])), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	f := os.NewFile(fd, "journal")
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSeals); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+func (w *JournalWriter) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package log
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_JournalWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := NewJournalWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logger := NewLogger(w, LogLevelInfo, 0)
+	logger.SetFormatter(&JournalFormatter{AppName: "app"})
+
+	logger.With("user_id", 7).Warnln("small")
+	data := readJournal(t, conn)
+	if expected := "PRIORITY=4\nSYSLOG_IDENTIFIER=app\nMESSAGE=small\nUSER_ID=7\n"; data != expected {
+		t.Fatalf("expected %q, got %q", expected, data)
+	}
+
+	// NOTE: larger than the default limit of datagrams, so that it is
+	// passed by a file descriptor
+	large := strings.Repeat("x", 4<<20)
+	logger.Infoln(large)
+	if data := readJournal(t, conn); data != "PRIORITY=6\nSYSLOG_IDENTIFIER=app\nMESSAGE="+large+"\n" {
+		t.Fatalf("unexpected large entry of %d bytes", len(data))
+	}
+
+	if _, err := NewJournalWriter(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Fatal("expected an error for a missing socket")
+	}
+}
+
+// readJournal reads an entry from a datagram or a passed file descriptor
+func readJournal(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 64<<10)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oobn == 0 {
+		return string(buf[:n])
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package log
+
+import "errors"
+
+// JournalWriter is available on linux only
+type JournalWriter struct{}
+
+// NewJournalWriter always fails, journald runs on linux only
+func NewJournalWriter(path string) (*JournalWriter, error) {
+	return nil, errors.New("log: journal is supported on linux only")
+}
+
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("log: journal is supported on linux only")
+}
+
+func (w *JournalWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const hex = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	dst = appendJSONEscaped(dst, s)
+	return append(dst, '"')
+}
+
+// appendJSONEscaped appends s without quotes, escaping quotes, backslashes,
+// control characters and invalid UTF-8
+func appendJSONEscaped(dst []byte, s string) []byte {
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// NOTE: U+2028 and U+2029 break JavaScript parsers
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(dst, s[start:]...)
+}
+
+// appendJSONValue appends v as a JSON value, values which could not
+// be marshaled are written as their quoted fmt representation
+func appendJSONValue(dst []byte, v any) []byte {
+	switch f := v.(type) {
+	case nil:
+		return append(dst, "null"...)
+	case string:
+		return appendJSONString(dst, f)
+	case []byte:
+		return appendJSONString(dst, bytesToString(f))
+	case bool:
+		return strconv.AppendBool(dst, f)
+	case int:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int8:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int16:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int64:
+		return strconv.AppendInt(dst, f, 10)
+	case uint:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint8:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint16:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint64:
+		return strconv.AppendUint(dst, f, 10)
+	case uintptr:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case float32:
+		return appendJSONFloat(dst, float64(f), 32)
+	case float64:
+		return appendJSONFloat(dst, f, 64)
+	case time.Time:
+		dst = append(dst, '"')
+		dst = f.AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"')
+	case time.Duration:
+		return appendJSONString(dst, f.String())
+	case json.Marshaler:
+		if data, err := marshal(f); err == nil {
+			return append(dst, data...)
+		}
+		return appendJSONString(dst, fmt.Sprintf("%+v", v))
+	case error:
+		return appendJSONString(dst, fmt.Sprint(f))
+	case fmt.Stringer:
+		return appendJSONString(dst, fmt.Sprint(f))
+	}
+
+	if data, err := marshal(v); err == nil {
+		return append(dst, data...)
+	}
+	return appendJSONString(dst, fmt.Sprintf("%+v", v))
+}
+
+func appendJSONFloat(dst []byte, f float64, size int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(dst, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(dst, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, size)
+}
+
+// marshal never panics, a panic while marshaling is returned as an error
+func marshal(v any) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("log: panic while marshaling %T: %v", v, r)
+		}
+	}()
+	return json.Marshal(v)
+}
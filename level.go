@@ -0,0 +1,269 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level is the severity of a log, it could be used as a flag.Value or a
+// field of a config struct, e.g.
+//
+//	level := log.LogLevelInfo
+//	flag.Var(&level, "log-level", "log level")
+//
+// Levels are written by their names in text and JSON, see RegisterLevel.
+type Level int
+
+// levelUnspecifiedName is the name of LogLevelUnspecified, which is not a
+// registered level
+const levelUnspecifiedName = "UNSPECIFIED"
+
+// ParseLevel returns the level of a case insensitive name, the names of
+// unregistered levels written by String such as "LEVEL(35)" are accepted
+func ParseLevel(name string) (Level, error) {
+	if def, ok := LookupLevel(name); ok {
+		return def.Severity, nil
+	}
+	upper := strings.ToUpper(name)
+	if upper == levelUnspecifiedName {
+		return LogLevelUnspecified, nil
+	}
+	if s, ok := strings.CutPrefix(upper, "LEVEL("); ok {
+		if s, ok := strings.CutSuffix(s, ")"); ok {
+			if n, err := strconv.Atoi(s); err == nil {
+				return Level(n), nil
+			}
+		}
+	}
+	return LogLevelUnspecified, fmt.Errorf("log: unknown level %q", name)
+}
+
+// String returns the name of the level
+func (l Level) String() string {
+	return getLogLevel(l)
+}
+
+// Set implements flag.Value
+func (l *Level) Set(name string) error {
+	lv, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	*l = lv
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (l *Level) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+// MarshalJSON implements json.Marshaler
+func (l Level) MarshalJSON() ([]byte, error) {
+	return appendJSONString(nil, l.String()), nil
+}
+
+// legacyLevels maps the numbers of the builtin levels before they were
+// spaced by 10, so that numeric levels of existing configs keep working
+var legacyLevels = [...]Level{LogLevelUnspecified, LogLevelTrace, LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError, LogLevelFatal}
+
+// UnmarshalJSON implements json.Unmarshaler, it accepts a name as well as
+// the severity of a registered level. The numbers 1 to 6 are the builtin
+// levels TRACE to FATAL, as they were numbered before, unless a level of
+// that severity is registered.
+func (l *Level) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+		return l.Set(name)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("log: invalid level %s", data)
+	}
+	if _, ok := levelDef(Level(n)); ok || n == int(LogLevelUnspecified) {
+		*l = Level(n)
+		return nil
+	}
+	if n > 0 && n < len(legacyLevels) {
+		*l = legacyLevels[n]
+		return nil
+	}
+	return fmt.Errorf("log: unknown level %d", n)
+}
+
+// syslog severities of RFC 5424
+const (
+	SyslogEmergency = iota
+	SyslogAlert
+	SyslogCritical
+	SyslogError
+	SyslogWarning
+	SyslogNotice
+	SyslogInfo
+	SyslogDebug
+)
+
+const colorReset = "\033[0m"
+
+// LevelDef defines a level, levels with a higher Severity are more severe
+type LevelDef struct {
+	Severity Level
+	Name     string
+	// Color is the ANSI escape sequence written before a colored entry,
+	// e.g. "\033[35m", an empty Color leaves the entry uncolored
+	Color string
+	// Syslog is the syslog severity of the level, e.g. SyslogNotice
+	Syslog int
+}
+
+// levelTable is an immutable snapshot of the registered levels
+type levelTable struct {
+	bySeverity map[Level]LevelDef
+	// byName is keyed by upper case names
+	byName map[string]LevelDef
+	// severities are sorted in ascending order
+	severities []Level
+}
+
+var (
+	levelsMu sync.Mutex
+	// NOTE: levels is set up by a variable initializer rather than init, so
+	// that it is ready before fastlogger is created
+	levels = builtinLevels()
+)
+
+func builtinLevels() *atomic.Pointer[levelTable] {
+	t := &levelTable{bySeverity: map[Level]LevelDef{}, byName: map[string]LevelDef{}}
+	for _, def := range []LevelDef{
+		{Severity: LogLevelTrace, Name: EnvLogLevelTrace, Syslog: SyslogDebug},
+		{Severity: LogLevelDebug, Name: EnvLogLevelDebug, Color: "\033[1;34m", Syslog: SyslogDebug},
+		{Severity: LogLevelInfo, Name: EnvLogLevelInfo, Syslog: SyslogInfo},
+		{Severity: LogLevelWarn, Name: EnvLogLevelWarn, Syslog: SyslogWarning},
+		{Severity: LogLevelError, Name: EnvLogLevelError, Color: "\033[31m", Syslog: SyslogError},
+		{Severity: LogLevelFatal, Name: EnvLogLevelFatal, Syslog: SyslogCritical},
+	} {
+		t.add(def)
+	}
+	p := &atomic.Pointer[levelTable]{}
+	p.Store(t)
+	return p
+}
+
+func (t *levelTable) add(def LevelDef) {
+	t.bySeverity[def.Severity] = def
+	t.byName[strings.ToUpper(def.Name)] = def
+	t.severities = append(t.severities, def.Severity)
+	slices.Sort(t.severities)
+}
+
+// RegisterLevel registers a custom level such as NOTICE, which could be
+// logged by Logf and Logln and parsed by SetLevelByName, e.g.
+//
+//	const LevelNotice = log.LogLevelInfo + 5
+//	log.RegisterLevel(log.LevelDef{Severity: LevelNotice, Name: "NOTICE", Syslog: log.SyslogNotice})
+//
+// Both the severity and the case insensitive name must be unused.
+func RegisterLevel(def LevelDef) error {
+	if def.Severity <= LogLevelUnspecified {
+		return fmt.Errorf("log: severity of level %s must be positive", def.Name)
+	}
+	if len(def.Name) == 0 || strings.ContainsAny(def.Name, " =,\"()") || strings.EqualFold(def.Name, levelUnspecifiedName) {
+		return fmt.Errorf("log: invalid level name %q", def.Name)
+	}
+	if def.Syslog < SyslogEmergency || def.Syslog > SyslogDebug {
+		return fmt.Errorf("log: invalid syslog severity %d of level %s", def.Syslog, def.Name)
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+
+	old := levels.Load()
+	if exist, ok := old.bySeverity[def.Severity]; ok {
+		return fmt.Errorf("log: severity %d is used by level %s", def.Severity, exist.Name)
+	}
+	if _, ok := old.byName[strings.ToUpper(def.Name)]; ok {
+		return fmt.Errorf("log: level %s is registered", def.Name)
+	}
+
+	t := &levelTable{
+		bySeverity: make(map[Level]LevelDef, len(old.bySeverity)+1),
+		byName:     make(map[string]LevelDef, len(old.byName)+1),
+		severities: make([]Level, 0, len(old.severities)+1),
+	}
+	for _, severity := range old.severities {
+		t.add(old.bySeverity[severity])
+	}
+	t.add(def)
+	levels.Store(t)
+	return nil
+}
+
+// LookupLevel returns the definition of a level by its case insensitive name
+func LookupLevel(name string) (LevelDef, bool) {
+	def, ok := levels.Load().byName[strings.ToUpper(name)]
+	return def, ok
+}
+
+// levelDef returns the definition of a level by its severity, which is the
+// way entries refer to levels
+func levelDef(level Level) (LevelDef, bool) {
+	def, ok := levels.Load().bySeverity[level]
+	return def, ok
+}
+
+// stepLevel returns the registered level delta steps away from level
+func stepLevel(level Level, delta int) Level {
+	severities := levels.Load().severities
+	// NOTE: i is the first registered level not below level
+	i, found := slices.BinarySearch(severities, level)
+	switch {
+	case delta < 0:
+		i += delta
+	case delta > 0:
+		if found {
+			i += delta
+		} else {
+			i += delta - 1
+		}
+	default:
+		return level
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(severities) {
+		i = len(severities) - 1
+	}
+	return severities[i]
+}
@@ -14,50 +14,195 @@
 
 package log
 
+// NOTE: functions of this file call the internal methods of fastlogger
+// directly, so that the caller is found at the same depth as the methods
+// of Logger
+
+func Logf(level Level, format string, v ...interface{}) {
+	if !fastlogger.Enabled(level) {
+		return
+	}
+	fastlogger._printf(level, format, v...)
+}
+
+func Logln(level Level, msg string) {
+	if !fastlogger.Enabled(level) {
+		return
+	}
+	fastlogger._println(level, msg)
+}
+
 func Traceln(msg string) {
-	fastlogger.Traceln(msg)
+	if !fastlogger.Enabled(LogLevelTrace) {
+		return
+	}
+	fastlogger._println(LogLevelTrace, msg)
 }
 
 func Tracef(format string, v ...interface{}) {
-	fastlogger.Tracef(format, v...)
+	if !fastlogger.Enabled(LogLevelTrace) {
+		return
+	}
+	fastlogger._printf(LogLevelTrace, format, v...)
 }
 
 func Debugln(msg string) {
-	fastlogger.Debugln(msg)
+	if !fastlogger.Enabled(LogLevelDebug) {
+		return
+	}
+	fastlogger._println(LogLevelDebug, msg)
 }
 
 func Debugf(format string, v ...interface{}) {
-	fastlogger.Debugf(format, v...)
+	if !fastlogger.Enabled(LogLevelDebug) {
+		return
+	}
+	fastlogger._printf(LogLevelDebug, format, v...)
 }
 
 func Infoln(msg string) {
-	fastlogger.Infoln(msg)
+	if !fastlogger.Enabled(LogLevelInfo) {
+		return
+	}
+	fastlogger._println(LogLevelInfo, msg)
 }
 
 func Infof(format string, v ...interface{}) {
-	fastlogger.Infof(format, v...)
+	if !fastlogger.Enabled(LogLevelInfo) {
+		return
+	}
+	fastlogger._printf(LogLevelInfo, format, v...)
 }
 
 func Warnln(msg string) {
-	fastlogger.Warnln(msg)
+	if !fastlogger.Enabled(LogLevelWarn) {
+		return
+	}
+	fastlogger._println(LogLevelWarn, msg)
 }
 
 func Warnf(format string, v ...interface{}) {
-	fastlogger.Warnf(format, v...)
+	if !fastlogger.Enabled(LogLevelWarn) {
+		return
+	}
+	fastlogger._printf(LogLevelWarn, format, v...)
 }
 
 func Errorln(msg string) {
-	fastlogger.Errorln(msg)
+	if !fastlogger.Enabled(LogLevelError) {
+		return
+	}
+	fastlogger._println(LogLevelError, msg)
 }
 
 func Errorf(format string, v ...interface{}) {
-	fastlogger.Errorf(format, v...)
+	if !fastlogger.Enabled(LogLevelError) {
+		return
+	}
+	fastlogger._printf(LogLevelError, format, v...)
 }
 
 func Fatalln(msg string) {
-	fastlogger.Fatalln(msg)
+	if !fastlogger.Enabled(LogLevelFatal) {
+		return
+	}
+	fastlogger._println(LogLevelFatal, msg)
 }
 
 func Fatalf(format string, v ...interface{}) {
-	fastlogger.Fatalf(format, v...)
+	if !fastlogger.Enabled(LogLevelFatal) {
+		return
+	}
+	fastlogger._printf(LogLevelFatal, format, v...)
+}
+
+func Tracew(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelTrace) {
+		return
+	}
+	fastlogger._printw(LogLevelTrace, msg, fields)
+}
+
+func Debugw(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelDebug) {
+		return
+	}
+	fastlogger._printw(LogLevelDebug, msg, fields)
+}
+
+func Infow(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelInfo) {
+		return
+	}
+	fastlogger._printw(LogLevelInfo, msg, fields)
+}
+
+func Warnw(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelWarn) {
+		return
+	}
+	fastlogger._printw(LogLevelWarn, msg, fields)
+}
+
+func Errorw(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelError) {
+		return
+	}
+	fastlogger._printw(LogLevelError, msg, fields)
+}
+
+func Fatalw(msg string, fields ...Field) {
+	if !fastlogger.Enabled(LogLevelFatal) {
+		return
+	}
+	fastlogger._printw(LogLevelFatal, msg, fields)
+}
+
+func Println(v ...any) {
+	if !fastlogger.Enabled(LogLevelInfo) {
+		return
+	}
+	fastlogger._print(LogLevelInfo, v)
+}
+
+func Trace(v ...any) {
+	if !fastlogger.Enabled(LogLevelTrace) {
+		return
+	}
+	fastlogger._print(LogLevelTrace, v)
+}
+
+func Debug(v ...any) {
+	if !fastlogger.Enabled(LogLevelDebug) {
+		return
+	}
+	fastlogger._print(LogLevelDebug, v)
+}
+
+func Info(v ...any) {
+	if !fastlogger.Enabled(LogLevelInfo) {
+		return
+	}
+	fastlogger._print(LogLevelInfo, v)
+}
+
+func Warn(v ...any) {
+	if !fastlogger.Enabled(LogLevelWarn) {
+		return
+	}
+	fastlogger._print(LogLevelWarn, v)
+}
+
+func Error(v ...any) {
+	if !fastlogger.Enabled(LogLevelError) {
+		return
+	}
+	fastlogger._print(LogLevelError, v)
+}
+
+func Fatal(v ...any) {
+	if !fastlogger.Enabled(LogLevelFatal) {
+		return
+	}
+	fastlogger._print(LogLevelFatal, v)
 }
@@ -15,10 +15,24 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_FastLogger(t *testing.T) {
@@ -39,26 +53,26 @@ func Test_GeneralLogger(t *testing.T) {
 	logger.SetColor(true)
 
 	logger.SetLevelByName("TRACE")
-	printall(logger, logger.Level)
+	printall(logger, logger.GetLevel())
 
 	logger.SetLevelByName("DEBUG")
-	printall(logger, logger.Level)
+	printall(logger, logger.GetLevel())
 
 	logger.SetLevelByName("INFO")
-	printall(logger, logger.Level)
+	printall(logger, logger.GetLevel())
 
 	logger.SetLevelByName("WARN")
-	printall(logger, logger.Level)
+	printall(logger, logger.GetLevel())
 
 	logger.SetLevelByName("ERROR")
-	printall(logger, logger.Level)
+	printall(logger, logger.GetLevel())
 
 	// logger.SetLevelByName("FATAL")
-	// printall(logger, logger.Level)
+	// printall(logger, logger.GetLevel())
 }
 
-func printall(l *Logger, level int) {
-	lv := LogLevelMap[level]
+func printall(l *Logger, level Level) {
+	lv := level.String()
 	str := fmt.Sprintf("current level is %s", lv)
 
 	l.Traceln("traceln: " + str)
@@ -92,3 +106,861 @@ func Test_GetShortFileName(t *testing.T) {
 	logger := NewLogger(os.Stdout, LogLevelInfo, 3)
 	logger.Infoln(getShortFileName(name))
 }
+
+func Test_LevelFiltering(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+
+	logger.Debugln("debugln should be dropped")
+	logger.Debugf("debugf should be dropped %d\n", 1)
+	logger.Traceln("traceln should be dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("disabled levels were written: %q", buf.String())
+	}
+
+	logger.Infoln("infoln should be written")
+	if !strings.Contains(buf.String(), "[INFO] msg=infoln should be written") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	if logger.Enabled(LogLevelDebug) || !logger.Enabled(LogLevelWarn) {
+		t.Fatalf("unexpected Enabled result at level %s", logger.GetLevel())
+	}
+	logger.SetLevel(LogLevelDebug)
+	if !logger.Enabled(LogLevelDebug) {
+		t.Fatalf("debug should be enabled after SetLevel")
+	}
+}
+
+func Test_DisabledLevelAllocs(t *testing.T) {
+	logger := NewLogger(&bytes.Buffer{}, LogLevelInfo, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debugf("this is dropped")
+		logger.Traceln("this is dropped")
+	})
+	if allocs != 0 {
+		t.Fatalf("disabled calls allocated %v times", allocs)
+	}
+}
+
+func Test_ChildLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+
+	ctx := Context{"request_id": "abc"}
+	child := logger.With("service", "billing").WithContext(ctx)
+
+	child.Infoln("charged")
+	if !strings.Contains(buf.String(), "[INFO] request_id=abc service=billing msg=charged") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+	if len(ctx) != 1 {
+		t.Fatalf("the context of the caller should not be modified: %v", ctx)
+	}
+
+	buf.Reset()
+	logger.Infoln("plain")
+	if strings.Contains(buf.String(), "service") {
+		t.Fatalf("fields of the child leaked into the parent: %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetLevel(LogLevelWarn)
+	child.Infoln("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("child should share the level of the parent: %q", buf.String())
+	}
+
+	logger.SetFormatter(&JSONFormatter{})
+	child.With("odd").Warnln("json")
+	if !strings.Contains(buf.String(), `"msg":"json","!BADKEY1":"odd","request_id":"abc","service":"billing"}`) {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	child.With(1, "a", 2, 3.5).With(true).Warnln("bad keys")
+	if !strings.Contains(buf.String(), `"msg":"bad keys","!BADKEY1":1,"!BADKEY2":3.5,"!BADKEY3":true,"a":2,`) {
+		t.Fatalf("every bad key should be kept: %q", buf.String())
+	}
+
+	for _, f := range []Formatter{&JSONFormatter{}, &TextFormatter{}} {
+		buf.Reset()
+		logger.SetFormatter(f)
+		child.Warnw("override", String("service", "payments"))
+		if strings.Count(buf.String(), "service") != 1 || !strings.Contains(buf.String(), "payments") {
+			t.Fatalf("fields of the call site should override the context: %q", buf.String())
+		}
+	}
+}
+
+func Test_TypedFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+
+	logger.Infow("served",
+		String("path", "/a b"),
+		Int("status", 200),
+		Bool("cached", true),
+		Float64("ratio", 0.5),
+		Duration("latency", 1500*time.Millisecond),
+		Time("at", time.Date(2024, 3, 8, 16, 30, 0, 0, time.UTC)),
+		Err(errors.New("boom")),
+	)
+	expected := `[INFO] path="/a b" status=200 cached=true ratio=0.5 latency=1.5s at=2024-03-08T16:30:00Z error=boom msg=served`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	logger.SetFormatter(&JSONFormatter{})
+	logger.With(Uint64("shard", 3)).Warnw("slow", Duration("latency", 2*time.Microsecond), Err(nil))
+	expected = `"msg":"slow","shard":3,"latency":"2µs","error":null}`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func Test_AppendDuration(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, -1, 999, 1500, 2 * time.Millisecond, 59 * time.Second, 90 * time.Minute, -26*time.Hour - 3, 1<<63 - 1, -1 << 63} {
+		if got := string(appendDuration(nil, d)); got != d.String() {
+			t.Errorf("expected %s, got %s", d, got)
+		}
+	}
+}
+
+func Test_TypedFieldsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable with the race detector")
+	}
+	logger := NewLogger(io.Discard, LogLevelInfo, 0)
+	for _, f := range []Formatter{&TextFormatter{}, &JSONFormatter{}} {
+		logger.SetFormatter(f)
+		allocs := testing.AllocsPerRun(100, func() {
+			logger.Infow("served", String("path", "/"), Int("status", 200), Duration("latency", time.Second))
+		})
+		if allocs != 0 {
+			t.Fatalf("%T allocated %v times", f, allocs)
+		}
+	}
+}
+
+type printPoint struct {
+	X, y int
+	Name string
+}
+
+type printStatus int
+
+func (s printStatus) String() string {
+	return "status-" + strconv.Itoa(int(s))
+}
+
+func Test_Println(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+
+	var nilptr *printPoint
+	cases := []struct {
+		args     []any
+		expected string
+	}{
+		{[]any{"a", 1, int8(-2), uint64(3), true, 1.5, float32(0.25)}, "a 1 -2 3 true 1.5 0.25"},
+		{[]any{[]byte("text"), []byte{0xff, 0x00}}, "text ff00"},
+		{[]any{nil, nilptr, errors.New("boom"), printStatus(7)}, "<nil> <nil> boom status-7"},
+		{[]any{map[string]int{"b": 2, "a": 1}, []printStatus{1, 2}}, "map[a:1 b:2] [status-1 status-2]"},
+		{[]any{printPoint{1, 2, "p"}, &printPoint{3, 4, "q"}}, "{1 2 p} &{3 4 q}"},
+		{[]any{map[string]any{"err": errors.New("x"), "n": nil}}, "map[err:x n:<nil>]"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		logger.Println(c.args...)
+		if !strings.HasSuffix(buf.String(), "msg="+c.expected+"\n") {
+			t.Errorf("expected %q, got %q", c.expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+	logger.Debug("dropped")
+	logger.Warn("kept", 1)
+	if !strings.HasSuffix(buf.String(), "[WARN] msg=kept 1\n") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+// wrappedInfof stands for the log function of a package wrapping a Logger
+func wrappedInfof(l *Logger, format string, v ...any) {
+	l.WithCallerSkip(1).Infof(format, v...)
+}
+
+func Test_Caller(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, CallPathDefault).EnableCaller()
+
+	expect := func(line int) {
+		t.Helper()
+		expected := fmt.Sprintf("[log.Test_Caller] [log_test.go:%d] ", line)
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected %q, got %q", expected, buf.String())
+		}
+		buf.Reset()
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	logger.Infof("direct\n")
+	expect(line + 1)
+
+	_, _, line, _ = runtime.Caller(0)
+	logger.With("k", "v").Infow("child")
+	expect(line + 1)
+
+	_, _, line, _ = runtime.Caller(0)
+	wrappedInfof(logger, "wrapped\n")
+	expect(line + 1)
+
+	saved := fastlogger
+	defer func() { fastlogger = saved }()
+	fastlogger = NewLogger(buf, LogLevelInfo, CallPathDefault).EnableCaller()
+
+	_, _, line, _ = runtime.Caller(0)
+	Infof("package level\n")
+	expect(line + 1)
+
+	_, _, line, _ = runtime.Caller(0)
+	Info("package level")
+	expect(line + 1)
+
+	fastlogger.SetFormatter(&JSONFormatter{})
+	_, _, line, _ = runtime.Caller(0)
+	Infoln("json")
+	expected := fmt.Sprintf(`"caller":"log_test.go:%d","func":"log.Test_Caller"`, line+1)
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+// blockingWriter blocks every write until release is closed, started
+// receives a value when a write begins
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return w.buf.Write(p)
+}
+
+func Test_AsyncLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0).EnableAsync()
+	// NOTE: the call path could be changed while entries are logged
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.SetCallPath(CallPathDefault)
+	}()
+	for i := 0; i < 100; i++ {
+		logger.Infof("line %d\n", i)
+	}
+	<-done
+	if !logger.Async() || logger.GetCallPath() != CallPathDefault {
+		t.Fatalf("unexpected async %t and call path %d", logger.Async(), logger.GetCallPath())
+	}
+	if err := logger.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 100 || logger.Dropped() != 0 {
+		t.Fatalf("expected 100 lines and no drop, got %d lines and %d drops", n, logger.Dropped())
+	}
+
+	if err := logger.Close(); err != nil || logger.Async() {
+		t.Fatalf("logger should be synchronous after close: %v", err)
+	}
+	logger.Infoln("written synchronously after close")
+	if !strings.HasSuffix(buf.String(), "msg=written synchronously after close\n") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func Test_AsyncOverflow(t *testing.T) {
+	cases := []struct {
+		policy   OverflowPolicy
+		expected []string
+	}{
+		{OverflowDropNewest, []string{"0", "1", "2"}},
+		{OverflowDropOldest, []string{"0", "8", "9"}},
+	}
+	for _, c := range cases {
+		w := &blockingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+		logger := NewLogger(w, LogLevelInfo, 0).EnableAsyncWith(2, c.policy)
+
+		logger.Infoln("0")
+		<-w.started
+		for i := 1; i < 10; i++ {
+			logger.Infoln(strconv.Itoa(i))
+		}
+		close(w.release)
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(w.buf.String()), "\n") {
+			got = append(got, line[strings.LastIndex(line, "=")+1:])
+		}
+		if strings.Join(got, ",") != strings.Join(c.expected, ",") || logger.Dropped() != 7 {
+			t.Errorf("policy %d: expected %v and 7 drops, got %v and %d drops", c.policy, c.expected, got, logger.Dropped())
+		}
+	}
+}
+
+// byteWriter writes one byte at a time, so concurrent writes would interleave
+type byteWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *byteWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.mu.Lock()
+		w.buf.WriteByte(b)
+		w.mu.Unlock()
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+func Test_ConcurrentLogger(t *testing.T) {
+	w := &byteWriter{}
+	logger := NewLogger(w, LogLevelInfo, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.With("goroutine", i).Infow("concurrent", Int("j", j))
+			}
+		}(i)
+	}
+
+	// NOTE: reconfiguration while logging must not race
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			logger.SetColor(j%2 == 0)
+			logger.SetLevel(LogLevelInfo)
+			logger.SetWriter(w)
+			logger.SetFormatter(&TextFormatter{})
+		}
+	}()
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(w.buf.String(), "\n"), "\n")
+	if len(lines) != 400 {
+		t.Fatalf("expected 400 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "msg=concurrent") || strings.Count(line, "msg=") != 1 {
+			t.Fatalf("interleaved line %q", line)
+		}
+	}
+}
+
+func Test_SlogHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelDebug, 0).EnableCaller()
+	logger.SetFormatter(&JSONFormatter{})
+
+	sl := slog.New(NewSlogHandler(logger)).With("service", "billing").WithGroup("req")
+	_, _, line, _ := runtime.Caller(0)
+	sl.Info("served", "status", 200, slog.Group("user", "id", 7), slog.Group("", "inline", true))
+	sl.Log(context.Background(), SlogLevelTrace, "dropped")
+	sl.Log(context.Background(), SlogLevelFatal, "not exiting")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	expected := fmt.Sprintf(`"level":"INFO","msg":"served","caller":"log_test.go:%d","func":"log.Test_SlogHandler","service":"billing","req.inline":true,"req.status":200,"req.user.id":7}`, line+1)
+	if !strings.HasSuffix(lines[0], expected) {
+		t.Fatalf("expected %q, got %q", expected, lines[0])
+	}
+	if !strings.Contains(lines[1], `"level":"FATAL","msg":"not exiting"`) {
+		t.Fatalf("unexpected line %q", lines[1])
+	}
+}
+
+func Test_LevelHandler(t *testing.T) {
+	db := NewLogger(io.Discard, LogLevelInfo, 0)
+	Register("db", db)
+	saved := fastlogger
+	defer func() {
+		fastlogger = saved
+		registry.Lock()
+		delete(registry.loggers, "db")
+		delete(registry.loggers, "cache")
+		delete(registry.levels, "cache")
+		registry.Unlock()
+	}()
+	out := &bytes.Buffer{}
+	fastlogger = NewLogger(out, LogLevelInfo, 0)
+
+	h := LevelHandler()
+	do := func(method, body, contentType string) (int, string) {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		if len(contentType) != 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := do(http.MethodPut, `{"logger":"db","level":"debug","ttl":"50ms"}`, "application/json")
+	if code != http.StatusOK || !strings.Contains(body, `"level":"DEBUG","revert_level":"INFO"`) {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	// NOTE: a second change keeps the level before the first one
+	code, _ = do(http.MethodPost, "logger=db&level=TRACE&ttl=50ms", "application/x-www-form-urlencoded")
+	if code != http.StatusOK || db.GetLevel() != LogLevelTrace {
+		t.Fatalf("unexpected response %d at level %d", code, db.GetLevel())
+	}
+
+	code, body = do(http.MethodGet, "", "")
+	if code != http.StatusOK || !strings.Contains(body, `{"logger":"db","level":"TRACE","revert_level":"INFO"`) || !strings.Contains(body, `{"logger":"","level":`) {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for db.GetLevel() != LogLevelInfo {
+		if time.Now().After(deadline) {
+			t.Fatal("level was not reverted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// NOTE: a named logger without a level of its own inherits again
+	cache := Named("cache")
+	if code, _ = do(http.MethodPut, `{"logger":"cache","level":"debug","ttl":"50ms"}`, "application/json"); code != http.StatusOK {
+		t.Fatalf("unexpected response %d", code)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for hasLevelFor("cache") {
+		if time.Now().After(deadline) {
+			t.Fatal("level of the named logger was not reset")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// NOTE: the handler holds its lock until the revert is logged
+	req := httptest.NewRequest(http.MethodGet, "/?logger=cache", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "revert_level") {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+	if !strings.Contains(out.String(), "logger=cache msg=log level reverted to INFO") {
+		t.Fatalf("the revert should log the inherited level, got %q", out.String())
+	}
+	fastlogger.SetLevel(LogLevelWarn)
+	if cache.GetLevel() != LogLevelWarn {
+		t.Fatalf("named logger should follow the default level after revert, got %s", cache.GetLevel())
+	}
+
+	if code, _ = do(http.MethodPut, `{"logger":"db","level":"verbose"}`, "application/json"); code != http.StatusBadRequest {
+		t.Fatalf("unknown level should be rejected, got %d", code)
+	}
+	if code, _ = do(http.MethodPut, `{"logger":"nope","level":"info"}`, "application/json"); code != http.StatusNotFound {
+		t.Fatalf("unknown logger should be rejected, got %d", code)
+	}
+	if code, _ = do(http.MethodDelete, "", ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE should not be allowed, got %d", code)
+	}
+}
+
+func Test_NamedLoggers(t *testing.T) {
+	buf := &bytes.Buffer{}
+	saved := fastlogger
+	defer func() {
+		fastlogger = saved
+		registry.Lock()
+		for _, name := range []string{"db", "db.pool", "dbx", "http"} {
+			delete(registry.loggers, name)
+			delete(registry.levels, name)
+		}
+		registry.Unlock()
+	}()
+	fastlogger = NewLogger(buf, LogLevelInfo, 0)
+
+	pool := Named("db.pool")
+	if Named("db.pool") != pool {
+		t.Fatal("Named should return the registered logger")
+	}
+	dbx, db := Named("dbx"), Named("db")
+
+	pool.Debugln("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("named loggers should inherit the default level: %q", buf.String())
+	}
+
+	if err := SetLevelSpec("db=DEBUG, http=WARN"); err != nil {
+		t.Fatal(err)
+	}
+	pool.Debugln("kept")
+	if !strings.Contains(buf.String(), "[DEBUG] logger=db.pool msg=kept") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	if dbx.Enabled(LogLevelDebug) || !db.Enabled(LogLevelDebug) {
+		t.Fatal("the level of db should only apply to db and its descendants")
+	}
+	if Named("http").Enabled(LogLevelInfo) {
+		t.Fatal("loggers created after SetLevelFor should take its level")
+	}
+
+	SetLevelFor("db.pool", LogLevelError)
+	if pool.Enabled(LogLevelWarn) || !db.Enabled(LogLevelDebug) {
+		t.Fatal("the longest prefix should win")
+	}
+	ResetLevelFor("db.pool")
+	ResetLevelFor("db")
+	fastlogger.SetLevel(LogLevelWarn)
+	if pool.Enabled(LogLevelInfo) {
+		t.Fatal("named loggers should follow the default level once reset")
+	}
+
+	if err := SetLevelSpec("db=LOUD"); err == nil {
+		t.Fatal("unknown level should be rejected")
+	}
+
+	replaced := &bytes.Buffer{}
+	fastlogger = NewLogger(replaced, LogLevelInfo, 0)
+	pool.With("id", 1).Infoln("after replace")
+	if !strings.Contains(replaced.String(), "logger=db.pool msg=after replace") {
+		t.Fatalf("named loggers should follow a replaced default logger, got %q", replaced.String())
+	}
+}
+
+func Test_VModule(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+
+	if err := logger.SetVModule("log_test=DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debugln("debug from log_test.go")
+	logger.Traceln("trace is still dropped")
+	if !strings.Contains(buf.String(), "msg=debug from log_test.go") || strings.Contains(buf.String(), "trace") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := logger.SetVModule("other=TRACE,*/log_te?t.go=ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warnln("dropped by an override above the level")
+	logger.Errorln("kept")
+	if strings.Count(buf.String(), "\n") != 1 || !strings.Contains(buf.String(), "msg=kept") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	if !logger.Enabled(LogLevelTrace) {
+		t.Fatal("Enabled should report levels which could be enabled by an override")
+	}
+
+	saved := fastlogger
+	defer func() { fastlogger = saved }()
+	fastlogger = NewLogger(buf, LogLevelInfo, 0)
+	buf.Reset()
+	if err := SetVModule("log_test=TRACE"); err != nil {
+		t.Fatal(err)
+	}
+	Tracef("package level %s\n", "trace")
+	if !strings.Contains(buf.String(), "msg=package level trace") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	if err := logger.SetVModule("log_test"); err == nil {
+		t.Fatal("a pattern without level should be rejected")
+	}
+	if err := logger.SetVModule("[=DEBUG"); err == nil {
+		t.Fatal("a bad pattern should be rejected")
+	}
+	if err := logger.SetVModule(""); err != nil || logger.Enabled(LogLevelTrace) {
+		t.Fatalf("an empty spec should remove overrides: %v", err)
+	}
+}
+
+// Benchmark_VModule shows the cost of vmodule rules for levels decided by
+// the level of the logger alone, and for levels which need the call site
+func Benchmark_VModule(b *testing.B) {
+	logger := NewLogger(io.Discard, LogLevelInfo, 0)
+	if err := logger.SetVModule("server=DEBUG"); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("warn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			logger.Warnf("kept %d", i)
+		}
+	})
+	b.Run("trace", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			logger.Tracef("dropped %d", i)
+		}
+	})
+	b.Run("debug", func(b *testing.B) {
+		// NOTE: DEBUG could be enabled by the rule, so the call site is needed
+		for i := 0; i < b.N; i++ {
+			logger.Debugf("dropped %d", i)
+		}
+	})
+}
+
+func Test_Verbose(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, CallPathDefault).EnableCaller()
+
+	logger.V(1).Infoln("dropped at verbosity 0")
+	if logger.V(1).Enabled() || !logger.V(0).Enabled() || buf.Len() != 0 {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	logger.SetVerbosity(2)
+	_, _, line, _ := runtime.Caller(0)
+	logger.V(2).Infof("compacted %d segments\n", 3)
+	expected := fmt.Sprintf("[INFO] [log.Test_Verbose] [log_test.go:%d] v=2 msg=compacted 3 segments\n", line+1)
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	logger.SetLevel(LogLevelWarn)
+	if logger.V(0).Enabled() {
+		t.Fatal("V should be disabled if INFO is disabled")
+	}
+
+	saved := fastlogger
+	defer func() { fastlogger = saved }()
+	fastlogger = NewLogger(buf, LogLevelInfo, 0)
+	fastlogger.SetFormatter(&JSONFormatter{})
+	SetVerbosity(1)
+	V(1).Infoln("package level")
+	if !strings.HasSuffix(buf.String(), `"msg":"package level","v":1}`+"\n") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func Test_RegisterLevel(t *testing.T) {
+	const notice = LogLevelInfo + 5
+	def := LevelDef{Severity: notice, Name: "NOTICE", Color: "\033[35m", Syslog: SyslogNotice}
+	saved := levels.Load()
+	defer levels.Store(saved)
+	if err := RegisterLevel(def); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLevel(LevelDef{Severity: notice, Name: "NOTICE2"}); err == nil {
+		t.Fatal("expected an error for a used severity")
+	}
+	if err := RegisterLevel(LevelDef{Severity: notice + 1, Name: "info"}); err == nil {
+		t.Fatal("expected an error for a used name")
+	}
+	if got, ok := LookupLevel("notice"); !ok || got != def {
+		t.Fatalf("unexpected level %+v", got)
+	}
+	if getLogLevel(notice) != "NOTICE" || getLogLevel(notice+1) != "LEVEL(36)" {
+		t.Fatalf("unexpected names %s %s", getLogLevel(notice), getLogLevel(notice+1))
+	}
+
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, CallPathDefault)
+	logger.SetLevelByName("notice")
+	if logger.GetLevel() != notice {
+		t.Fatalf("expected level %d, got %d", notice, logger.GetLevel())
+	}
+	logger.Infoln("dropped")
+	logger.Logf(notice, "disk %d%% full\n", 90)
+	if !strings.HasSuffix(buf.String(), " [NOTICE] msg=disk 90% full\n") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetColor(true)
+	logger.Logln(notice, "colored")
+	if !strings.HasPrefix(buf.String(), def.Color) || !strings.HasSuffix(buf.String(), colorReset+"\n") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	if stepLevel(LogLevelInfo, 1) != notice || stepLevel(notice, 1) != LogLevelWarn || stepLevel(notice+1, -1) != notice {
+		t.Fatal("stepLevel should stop at registered levels")
+	}
+	if stepLevel(LogLevelFatal, 1) != LogLevelFatal || stepLevel(LogLevelTrace, -3) != LogLevelTrace {
+		t.Fatal("stepLevel should stay between the registered levels")
+	}
+
+	mixed := LevelDef{Severity: notice + 2, Name: "Audit", Color: "\033[36m", Syslog: SyslogNotice}
+	if err := RegisterLevel(mixed); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	logger.Logln(mixed.Severity, "mixed case")
+	if !strings.HasPrefix(buf.String(), mixed.Color) {
+		t.Fatalf("level with a mixed case name should be colored, got %q", buf.String())
+	}
+	if e := (&LogEntry{}).SetLevel("Audit"); e.severity != mixed.Severity {
+		t.Fatalf("expected severity %d, got %d", mixed.Severity, e.severity)
+	}
+}
+
+func Test_LevelType(t *testing.T) {
+	level, err := ParseLevel("warn")
+	if err != nil || level != LogLevelWarn || level.String() != "WARN" {
+		t.Fatalf("unexpected level %v, error %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	level = LogLevelInfo
+	fs.Var(&level, "log-level", "log level")
+	if err := fs.Parse([]string{"-log-level", "Debug"}); err != nil || level != LogLevelDebug {
+		t.Fatalf("unexpected level %v, error %v", level, err)
+	}
+	if err := fs.Parse([]string{"-log-level", "loud"}); err == nil {
+		t.Fatal("expected an error for an unknown flag value")
+	}
+
+	var config struct {
+		Level   Level            `json:"level"`
+		Levels  map[string]Level `json:"levels"`
+		Numeric Level            `json:"numeric"`
+	}
+	data := `{"level":"error","levels":{"db":"TRACE"},"numeric":40}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != LogLevelError || config.Levels["db"] != LogLevelTrace || config.Numeric != LogLevelWarn {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	out, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"level":"ERROR","levels":{"db":"TRACE"},"numeric":"WARN"}`; string(out) != expected {
+		t.Fatalf("expected %s, got %s", expected, out)
+	}
+	if err := json.Unmarshal([]byte(`{"level":"nope"}`), &config); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+	if err := json.Unmarshal([]byte(`{"level":3,"numeric":6}`), &config); err != nil || config.Level != LogLevelInfo || config.Numeric != LogLevelFatal {
+		t.Fatalf("numbers of the former levels should be mapped, got %+v (%v)", config, err)
+	}
+	if err := json.Unmarshal([]byte(`{"level":35}`), &config); err == nil {
+		t.Fatal("expected an error for an unregistered severity")
+	}
+
+	for _, lv := range []Level{LogLevelFatal, LogLevelUnspecified, LogLevelInfo + 3} {
+		text, _ := lv.MarshalText()
+		if err := level.UnmarshalText(text); err != nil || level != lv {
+			t.Fatalf("%s should round trip, got %v, error %v", text, level, err)
+		}
+	}
+	if LogLevelUnspecified.String() != LogLevelMap[LogLevelUnspecified] {
+		t.Fatalf("unexpected name %s", LogLevelUnspecified)
+	}
+	if _, err := ParseLevel("LEVEL(x)"); err == nil {
+		t.Fatal("expected an error for an invalid level number")
+	}
+	if err := RegisterLevel(LevelDef{Severity: 1, Name: "unspecified"}); err == nil {
+		t.Fatal("expected an error for the name of the unspecified level")
+	}
+}
+
+func Test_NewLoggerFromEnv(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "env.log")
+	t.Setenv(EnvLogLevel, "warn")
+	t.Setenv(EnvLogFormat, "JSON")
+	t.Setenv(EnvLogTimeFormat, "RFC3339")
+	t.Setenv(EnvLogCaller, "1")
+	t.Setenv(EnvLogOutput, output)
+
+	logger, err := NewLoggerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.GetWriter().(io.Closer).Close()
+	if logger.GetLevel() != LogLevelWarn {
+		t.Fatalf("unexpected level %s", logger.GetLevel())
+	}
+	logger.Infoln("dropped")
+	_, _, line, _ := runtime.Caller(0)
+	logger.Warnln("from env")
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := map[string]any{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("unexpected output %q: %v", data, err)
+	}
+	if entry["msg"] != "from env" || entry["caller"] != "log_test.go:"+strconv.Itoa(line+1) {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339, entry["time"].(string)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvLogLevel, "loud")
+	t.Setenv(EnvLogFormat, "xml")
+	t.Setenv(EnvLogColor, "maybe")
+	t.Setenv(EnvLogTimeFormat, "now")
+	t.Setenv(EnvLogCaller, "")
+	t.Setenv(EnvLogOutput, "stderr")
+	logger, err = NewLoggerFromEnv()
+	if err == nil {
+		t.Fatal("expected errors for invalid values")
+	}
+	for _, key := range []string{EnvLogLevel, EnvLogFormat, EnvLogColor, EnvLogTimeFormat} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("%s is not reported in %q", key, err)
+		}
+	}
+	if logger.GetLevel() != LogLevelDefault || logger.GetWriter() != os.Stderr {
+		t.Fatalf("unexpected logger %s %v", logger.GetLevel(), logger.GetWriter())
+	}
+	if _, ok := logger.GetFormatter().(*TextFormatter); !ok {
+		t.Fatalf("unexpected formatter %T", logger.GetFormatter())
+	}
+}
+
+func Test_Sampling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0).SetSampling(time.Hour, 2, 3)
+
+	for i := 0; i < 10; i++ {
+		logger.Infoln("repeated")
+		logger.Infof("distinct %d\n", i)
+	}
+	// NOTE: the 1st, 2nd, 5th and 8th of the repeated ones are kept
+	if n := strings.Count(buf.String(), "msg=repeated"); n != 4 {
+		t.Fatalf("expected 4 sampled entries, got %d", n)
+	}
+	if n := strings.Count(buf.String(), "msg=distinct"); n != 10 {
+		t.Fatalf("expected 10 distinct entries, got %d", n)
+	}
+
+	buf.Reset()
+	logger.Warnln("repeated")
+	logger.SetSampling(0, 0, 0)
+	for i := 0; i < 3; i++ {
+		logger.Infoln("repeated")
+	}
+	if n := strings.Count(buf.String(), "msg=repeated"); n != 4 {
+		t.Fatalf("expected every entry without sampling, got %q", buf.String())
+	}
+}
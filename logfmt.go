@@ -0,0 +1,196 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter renders every entry as one logfmt line such as
+//
+//	time=2024-03-08T16:30:00.123+08:00 level=info msg="disk full" caller=main.go:12 func=main.main shard=3
+//
+// Context fields follow the builtin keys in key order. Values are quoted
+// if they are empty or contain spaces, '=', quotes, backslashes or non
+// printable characters, invalid characters of keys are replaced by '_'.
+type LogfmtFormatter struct {
+	Color bool
+	// TimestampFormat is time.RFC3339Nano if it is empty
+	TimestampFormat string
+}
+
+func (l *LogfmtFormatter) SetColor(color bool) {
+	l.Color = color
+}
+
+func (l *LogfmtFormatter) withColor(color bool) Formatter {
+	f := *l
+	f.Color = color
+	return &f
+}
+
+func (l *LogfmtFormatter) Render(e *LogEntry) {
+	format := l.TimestampFormat
+	if len(format) == 0 {
+		format = time.RFC3339Nano
+	}
+	e.SetTimestamp(format)
+
+	e.buf = append(e.buf, keyTime+"="...)
+	e.buf = appendLogfmtString(e.buf, bytesToString(e.timestamp))
+	e.buf = append(e.buf, " "+keyLevel+"="...)
+	for i := 0; i < len(e.level); i++ {
+		c := e.level[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		e.buf = append(e.buf, c)
+	}
+	e.buf = append(e.buf, " "+keyMsg+"="...)
+	e.buf = appendLogfmtString(e.buf, strings.TrimSuffix(e.msg, "\n"))
+
+	if len(e.file) != 0 {
+		e.buf = appendLogfmtCaller(e.buf, e)
+	}
+
+	e.buf = append(e.buf, e.encoded...)
+	e.buf = appendLogfmtContext(e.buf, e.context)
+	for i := range e.fields {
+		e.buf = appendLogfmtKey(e.buf, e.fields[i].Key)
+		e.buf = e.fields[i].appendLogfmt(e.buf)
+	}
+
+	e.buf = append(e.buf, '\n')
+}
+
+// appendLogfmtCaller appends the caller pairs of e, each of them is
+// preceded by a space
+func appendLogfmtCaller(dst []byte, e *LogEntry) []byte {
+	dst = append(dst, " "+keyCaller+"="...)
+	if needsQuote(e.file, true) {
+		dst = appendLogfmtQuoted(dst, e.file+":"+strconv.Itoa(e.line))
+	} else {
+		dst = append(dst, e.file...)
+		dst = append(dst, ':')
+		dst = strconv.AppendInt(dst, int64(e.line), 10)
+	}
+	dst = append(dst, " "+keyFunc+"="...)
+	return appendLogfmtString(dst, e.funcname)
+}
+
+func (l *LogfmtFormatter) encodeContext(dst []byte, ctx Context) []byte {
+	return appendLogfmtContext(dst, ctx)
+}
+
+// appendLogfmtContext appends ctx as sorted pairs, each of them is
+// preceded by a space
+func appendLogfmtContext(dst []byte, ctx Context) []byte {
+	for _, k := range ctx.sortedKeys() {
+		dst = appendLogfmtKey(dst, k)
+		dst = appendLogfmtValue(dst, ctx[k])
+	}
+	return dst
+}
+
+// appendLogfmtKey appends " key=", reserved keys are prefixed like the
+// other formatters do
+func appendLogfmtKey(dst []byte, k string) []byte {
+	dst = append(dst, ' ')
+	if isReservedKey(k) {
+		dst = append(dst, fieldsPrefix...)
+	}
+	if len(k) == 0 {
+		dst = append(dst, '_')
+	}
+	for i := 0; i < len(k); {
+		r, size := utf8.DecodeRuneInString(k[i:])
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || r == utf8.RuneError || !strconv.IsPrint(r) {
+			dst = append(dst, '_')
+		} else {
+			dst = append(dst, k[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '=')
+}
+
+// appendLogfmtString appends s as a logfmt value, the string "null" is
+// quoted so that it is not mistaken for nil
+func appendLogfmtString(dst []byte, s string) []byte {
+	if s == "null" || needsQuote(s, true) {
+		return appendLogfmtQuoted(dst, s)
+	}
+	return append(dst, s...)
+}
+
+// appendLogfmtQuoted appends s quoted, quotes and backslashes are escaped
+// by a backslash, control characters by \n, \r, \t or \u00XX, and invalid
+// UTF-8 by \ufffd
+func appendLogfmtQuoted(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b < ' ' || b == 0x7f:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			default:
+				dst = append(dst, b)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, `\ufffd`...)
+		case !strconv.IsPrint(r) && r <= 0xFFFF:
+			dst = append(dst, '\\', 'u', hex[r>>12], hex[r>>8&0xF], hex[r>>4&0xF], hex[r&0xF])
+		default:
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// appendLogfmtValue appends v as a logfmt value, nil is written as null
+func appendLogfmtValue(dst []byte, v any) []byte {
+	if v == nil {
+		return append(dst, "null"...)
+	}
+	return appendScalar(dst, v, appendLogfmtString)
+}
+
+func (f Field) appendLogfmt(dst []byte) []byte {
+	switch f.typ {
+	case stringType:
+		return appendLogfmtString(dst, f.str)
+	case intType, uintType, boolType, floatType, durationType, timeType:
+		return f.appendText(dst)
+	}
+	return appendLogfmtValue(dst, f.iface)
+}
@@ -18,119 +18,370 @@ package log
 import (
 	"io"
 	"os"
-	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// Logger is safe for concurrent use. Entries are rendered concurrently and
+// written one at a time, the writer, formatter and level are swapped as
+// atomic snapshots, so they could be changed while logging.
 type Logger struct {
-	Writer io.Writer
+	// mu guards the configuration, wmu serializes writes to the writer
+	mu  sync.Mutex
+	wmu sync.Mutex
 
-	mu        sync.Mutex
-	formatter Formatter
-	epool     sync.Pool
+	writer    atomic.Pointer[writerRef]
+	formatter atomic.Pointer[formatterRef]
+	epool     *sync.Pool
 
 	// Sink     Sink
 
-	Level int
-	// TODO: remove this later
-	LevelStr string
+	// level is the gate consulted by Enabled
+	level atomic.Int32
 
-	CallPath int
-	Async    bool
+	// callPath is the depth of the caller, see SetCallPath
+	callPath atomic.Int32
+
+	// queue is not nil if the logger is async
+	queue   atomic.Pointer[asyncQueue]
+	dropped atomic.Uint64
+
+	// caller enables the caller information, callerSkip is the number of
+	// extra frames skipped by a child created with WithCallerSkip
+	caller     atomic.Bool
+	callerSkip int
+
+	// root owns the writer, formatter and level of a child logger created
+	// by With, it is nil for loggers created by NewLogger
+	root   *Logger
+	fields atomic.Pointer[loggerFields]
+
+	// name and lvl are set for loggers created by Named, lvl overrides the
+	// level of root unless it is levelInherit
+	name string
+	lvl  *atomic.Int32
+
+	vmodule   atomic.Pointer[vmodule]
+	verbosity atomic.Int32
+
+	sampler atomic.Pointer[sampler]
+}
+
+// core returns the logger which owns the writer, formatter and level
+func (l *Logger) core() *Logger {
+	// NOTE: named loggers are resolved at log time, so that they follow
+	// the default logger when it is replaced by NewDefaultLogger
+	if l.lvl != nil {
+		return fastlogger
+	}
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
+
+type writerRef struct {
+	w io.Writer
+}
+
+type formatterRef struct {
+	f Formatter
 }
 
+// SetWriter replaces the writer, entries being written are not affected
 func (l *Logger) SetWriter(w io.Writer) *Logger {
-	l.Writer = w
+	l.core().writer.Store(&writerRef{w: w})
 	return l
 }
 
+func (l *Logger) GetWriter() io.Writer {
+	return l.core().writer.Load().w
+}
+
+// SetColor replaces the formatter with a copy of the desired color if it
+// is one of this package, other formatters are changed in place
 func (l *Logger) SetColor(enabled bool) *Logger {
-	l.formatter.SetColor(enabled)
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.GetFormatter()
+	if cf, ok := f.(colorCloner); ok {
+		c.formatter.Store(&formatterRef{f: cf.withColor(enabled)})
+	} else {
+		f.SetColor(enabled)
+	}
 	return l
 }
 
 func (l *Logger) SetFormatter(f Formatter) *Logger {
-	l.formatter = f
+	l.core().formatter.Store(&formatterRef{f: f})
 	return l
 }
 
+func (l *Logger) GetFormatter() Formatter {
+	return l.core().formatter.Load().f
+}
+
+// write writes b to the current writer, writes never interleave. The
+// level is passed to a LevelWriter.
+func (l *Logger) write(level Level, b []byte) error {
+	l.wmu.Lock()
+	defer l.wmu.Unlock()
+
+	w := l.writer.Load().w
+	if lw, ok := w.(LevelWriter); ok {
+		_, err := lw.WriteLevel(level, b)
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// EnableAsync makes the logger write entries in a background goroutine,
+// with a queue of DefaultAsyncQueueSize entries which blocks when full
 func (l *Logger) EnableAsync() *Logger {
-	l.Async = true
+	return l.EnableAsyncWith(DefaultAsyncQueueSize, OverflowBlock)
+}
+
+// EnableAsyncWith makes the logger write entries in a background goroutine,
+// at most size entries are queued and policy decides what to do beyond that.
+// Flush or Close should be called before the program exits.
+func (l *Logger) EnableAsyncWith(size int, policy OverflowPolicy) *Logger {
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.queue.Load() != nil {
+		return l
+	}
+	q := newAsyncQueue(size, policy, &c.dropped)
+	c.queue.Store(q)
+	go c.flushDaemon(q)
 	return l
 }
 
-// SetLevel set the level of log
-func (l *Logger) SetLevel(level int) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.Level = level
-	l.LevelStr = getLogLevel(level)
+// Async reports whether the logger writes entries in a background goroutine
+func (l *Logger) Async() bool {
+	return l.core().queue.Load() != nil
 }
 
-// SetLevelByName set the log level by name
-func (l *Logger) SetLevelByName(level string) {
-	if strings.EqualFold(level, EnvLogLevelError) {
-		l.SetLevel(LogLevelError)
+// Dropped returns the number of entries dropped by an async logger
+func (l *Logger) Dropped() uint64 {
+	return l.core().dropped.Load()
+}
+
+// Flush waits until all entries queued by an async logger are written,
+// it returns the first error of writing since the last Flush
+func (l *Logger) Flush() error {
+	if q := l.core().queue.Load(); q != nil {
+		return q.flush()
 	}
-	if strings.EqualFold(level, EnvLogLevelWarn) {
-		l.SetLevel(LogLevelWarn)
+	return nil
+}
+
+// Close drains the queue of an async logger and stops its goroutine,
+// the logger writes synchronously afterwards
+func (l *Logger) Close() error {
+	c := l.core()
+	c.mu.Lock()
+	q := c.queue.Swap(nil)
+	c.mu.Unlock()
+
+	if q != nil {
+		return q.close()
 	}
-	if strings.EqualFold(level, EnvLogLevelInfo) {
-		l.SetLevel(LogLevelInfo)
+	return nil
+}
+
+// SetLevel set the level of log
+func (l *Logger) SetLevel(level Level) {
+	// NOTE: a named logger shares its level with its descendants
+	if l.lvl != nil {
+		SetLevelFor(l.name, level)
+		return
 	}
-	if strings.EqualFold(level, EnvLogLevelDebug) {
-		l.SetLevel(LogLevelDebug)
+
+	l.core().level.Store(int32(level))
+}
+
+// stepLevel moves the level by delta registered levels, a negative delta
+// means more verbose, the result stays between the registered levels
+func (l *Logger) stepLevel(delta int) (from, to Level) {
+	from = l.GetLevel()
+	to = stepLevel(from, delta)
+	if to != from {
+		l.SetLevel(to)
 	}
-	if strings.EqualFold(level, EnvLogLevelTrace) {
-		l.SetLevel(LogLevelTrace)
+	return from, to
+}
+
+// notice writes msg at INFO level regardless of the current level, it is
+// used for messages about the logger itself
+func (l *Logger) notice(msg string) {
+	e := l.GetLogEntry().SetMsg(msg).setSeverity(LogLevelInfo).SetNewline()
+	defer l.PutLogEntry(e)
+
+	l.emit(e)
+}
+
+// GetLevel returns the current level
+func (l *Logger) GetLevel() Level {
+	return Level(l.threshold())
+}
+
+// threshold returns the lowest enabled level
+func (l *Logger) threshold() int32 {
+	if l.lvl != nil {
+		if lv := l.lvl.Load(); lv != int32(levelInherit) {
+			return lv
+		}
+	}
+	return l.core().level.Load()
+}
+
+// Enabled reports whether a log of the given level would be written,
+// it is cheap enough to be called before building any message. With
+// SetVModule it reports whether the level is enabled for any call site.
+func (l *Logger) Enabled(level Level) bool {
+	if int32(level) >= l.threshold() {
+		return true
+	}
+	vm := l.core().vmodule.Load()
+	return vm != nil && int32(level) >= vm.min
+}
+
+// SetLevelByName set the log level by name
+func (l *Logger) SetLevelByName(level string) {
+	if lv, err := ParseLevel(level); err == nil {
+		l.SetLevel(lv)
 	}
 }
 
 // SetCallPath set caller path
 func (l *Logger) SetCallPath(callPath int) {
-	l.CallPath = callPath
+	l.callPath.Store(int32(callPath))
+}
+
+// GetCallPath returns the caller path
+func (l *Logger) GetCallPath() int {
+	return int(l.callPath.Load())
+}
+
+// EnableCaller annotates every entry with the file, line and function
+// where it is logged, it applies to the children of the logger as well
+func (l *Logger) EnableCaller() *Logger {
+	l.core().caller.Store(true)
+	return l
+}
+
+// callerDepth returns the depth of the caller of a log method seen from
+// output, which is one frame deeper than _printf and the like
+func (l *Logger) callerDepth() int {
+	depth := int(l.callPath.Load())
+	if depth <= 0 {
+		depth = CallPathDefault
+	}
+	return depth + l.callerSkip + 1
 }
 
 func (l *Logger) GetLogEntry() *LogEntry {
-	return l.epool.Get().(*LogEntry)
+	return l.core().epool.Get().(*LogEntry)
 }
 
 func (l *Logger) PutLogEntry(e *LogEntry) {
 	e.reset()
-	l.epool.Put(e)
+	l.core().epool.Put(e)
 }
 
-func (l *Logger) _printf(levelGate int, format string, v ...interface{}) {
-	msg := formattedMessage(format, v...)
-	e := l.GetLogEntry().SetMsg(msg)
-	defer l.PutLogEntry(e)
-
-	if levelGate >= l.Level {
-		e.SetLevel(LogLevelMap[levelGate])
+// output annotates the entry with its caller and emits it, the program
+// exits after a FATAL entry
+func (l *Logger) output(levelGate Level, e *LogEntry) {
+	if !l.sampled(levelGate, e.msg) {
+		return
+	}
+	c := l.core()
+	if c.caller.Load() {
+		e.SetCaller(getFuncInfo(l.callerDepth()))
 	}
 
-	l.formatter.Render(e)
-	_, _ = l.Writer.Write(e.Bytes())
+	l.emit(e)
 
 	if levelGate == LogLevelFatal {
+		_ = c.Flush()
 		os.Exit(1)
 	}
 }
 
-func (l *Logger) _println(levelGate int, msg string) {
-	e := l.GetLogEntry().SetMsg(msg).SetNewline()
+// emit renders the entry with the fields of the logger and writes it
+func (l *Logger) emit(e *LogEntry) {
+	c := l.core()
+	f := c.GetFormatter()
+	e.name = l.name
+	if lf := l.fields.Load(); lf != nil {
+		lf.apply(f, e)
+	}
+	e.shadowContext()
+
+	f.Render(e)
+	if q := c.queue.Load(); q == nil || !q.push(e.severity, e.Bytes()) {
+		_ = c.write(e.severity, e.Bytes())
+	}
+}
+
+func (l *Logger) _printf(levelGate Level, format string, v ...interface{}) {
+	if !l.enabled(levelGate) {
+		return
+	}
+
+	msg := formattedMessage(format, v...)
+	e := l.GetLogEntry().SetMsg(msg).setSeverity(levelGate)
 	defer l.PutLogEntry(e)
 
-	if levelGate >= l.Level {
-		e.SetLevel(LogLevelMap[levelGate])
+	l.output(levelGate, e)
+}
+
+func (l *Logger) _println(levelGate Level, msg string) {
+	if !l.enabled(levelGate) {
+		return
 	}
 
-	l.formatter.Render(e)
-	_, _ = l.Writer.Write(e.Bytes())
+	e := l.GetLogEntry().SetMsg(msg).setSeverity(levelGate).SetNewline()
+	defer l.PutLogEntry(e)
 
-	if levelGate == LogLevelFatal {
-		os.Exit(1)
+	l.output(levelGate, e)
+}
+
+func (l *Logger) _printw(levelGate Level, msg string, fields []Field) {
+	if !l.enabled(levelGate) {
+		return
 	}
+
+	e := l.GetLogEntry().SetMsg(msg).setSeverity(levelGate).SetNewline().WithFields(fields)
+	defer l.PutLogEntry(e)
+
+	l.output(levelGate, e)
+}
+
+func (l *Logger) _print(levelGate Level, v []any) {
+	if !l.enabled(levelGate) {
+		return
+	}
+
+	e := l.GetLogEntry().setSeverity(levelGate).SetNewline().SetArgs(v)
+	defer l.PutLogEntry(e)
+
+	l.output(levelGate, e)
+}
+
+// Logf logs at any level including the ones registered by RegisterLevel
+func (l *Logger) Logf(level Level, format string, v ...interface{}) {
+	l._printf(level, format, v...)
+}
+
+// Logln logs at any level including the ones registered by RegisterLevel
+func (l *Logger) Logln(level Level, msg string) {
+	l._println(level, msg)
 }
 
 func (l *Logger) Traceln(msg string) {
@@ -180,3 +431,56 @@ func (l *Logger) Errorf(format string, v ...interface{}) {
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	l._printf(LogLevelFatal, format, v...)
 }
+
+func (l *Logger) Tracew(msg string, fields ...Field) {
+	l._printw(LogLevelTrace, msg, fields)
+}
+
+func (l *Logger) Debugw(msg string, fields ...Field) {
+	l._printw(LogLevelDebug, msg, fields)
+}
+
+func (l *Logger) Infow(msg string, fields ...Field) {
+	l._printw(LogLevelInfo, msg, fields)
+}
+
+func (l *Logger) Warnw(msg string, fields ...Field) {
+	l._printw(LogLevelWarn, msg, fields)
+}
+
+func (l *Logger) Errorw(msg string, fields ...Field) {
+	l._printw(LogLevelError, msg, fields)
+}
+
+func (l *Logger) Fatalw(msg string, fields ...Field) {
+	l._printw(LogLevelFatal, msg, fields)
+}
+
+// Println logs v at INFO level, operands are printed without fmt
+func (l *Logger) Println(v ...any) {
+	l._print(LogLevelInfo, v)
+}
+
+func (l *Logger) Trace(v ...any) {
+	l._print(LogLevelTrace, v)
+}
+
+func (l *Logger) Debug(v ...any) {
+	l._print(LogLevelDebug, v)
+}
+
+func (l *Logger) Info(v ...any) {
+	l._print(LogLevelInfo, v)
+}
+
+func (l *Logger) Warn(v ...any) {
+	l._print(LogLevelWarn, v)
+}
+
+func (l *Logger) Error(v ...any) {
+	l._print(LogLevelError, v)
+}
+
+func (l *Logger) Fatal(v ...any) {
+	l._print(LogLevelFatal, v)
+}
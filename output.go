@@ -0,0 +1,89 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// LevelWriter is a writer which is told the level of every entry, a Logger
+// calls WriteLevel instead of Write if its writer implements it
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (n int, err error)
+}
+
+// Output is a writer which takes entries at or above Level only
+type Output struct {
+	Writer io.Writer
+	Level  Level
+}
+
+// MultiWriter duplicates entries to every Output whose level is enabled
+type MultiWriter struct {
+	outputs []Output
+	// keys identify the outputs opened from a Config, so that a reload
+	// keeps the unchanged ones open
+	keys []string
+}
+
+// NewMultiWriter returns a MultiWriter of outputs, an Output with the
+// unspecified level takes every entry
+func NewMultiWriter(outputs ...Output) *MultiWriter {
+	return &MultiWriter{outputs: outputs}
+}
+
+// Write writes p to every output regardless of their levels
+func (w *MultiWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(LogLevelUnspecified, p)
+}
+
+// WriteLevel writes p to the outputs enabled at level, a failed output
+// does not stop the others and the first error is returned
+func (w *MultiWriter) WriteLevel(level Level, p []byte) (int, error) {
+	var first error
+	for _, o := range w.outputs {
+		if level != LogLevelUnspecified && level < o.Level {
+			continue
+		}
+		if _, err := o.Writer.Write(p); err != nil && first == nil {
+			first = err
+		}
+	}
+	return len(p), first
+}
+
+// Close closes the writers of the outputs except stdout and stderr
+func (w *MultiWriter) Close() error {
+	var errs []error
+	for _, o := range w.outputs {
+		if err := closeOutput(o.Writer); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func closeOutput(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PatternFormatter renders entries by a layout in the manner of log4j,
+// e.g. "%d{2006-01-02T15:04:05} %-5p [%c] %F:%L %m%n". The verbs are
+//
+//	%d, %d{layout}  time, by a Go time layout if it is given
+//	%p              level
+//	%c              name of the logger created by Named
+//	%F, %L, %M      file, line and function of the caller, "?" without caller
+//	%m              message
+//	%X{key}         value of a context key or field, empty if it is missing
+//	%n              newline
+//	%%              percent sign
+//
+// A verb could be preceded by a minimum width, padded on the left or on
+// the right with '-', and a maximum width after '.', which keeps the end
+// of longer values, e.g. "%-5p" or "%.20c".
+type PatternFormatter struct {
+	// Color colors every line by its level, see LevelDef
+	Color bool
+
+	pattern   string
+	appenders []patternAppender
+}
+
+// patternAppender appends a part of the layout of e to dst
+type patternAppender func(dst []byte, e *LogEntry) []byte
+
+// NewPatternFormatter compiles pattern, an error is returned if it has an
+// unknown verb or a malformed argument
+func NewPatternFormatter(pattern string) (*PatternFormatter, error) {
+	f := &PatternFormatter{pattern: pattern}
+	for i := 0; i < len(pattern); {
+		j := strings.IndexByte(pattern[i:], '%')
+		if j < 0 {
+			f.appenders = append(f.appenders, literalAppender(pattern[i:]))
+			break
+		}
+		if j > 0 {
+			f.appenders = append(f.appenders, literalAppender(pattern[i:i+j]))
+		}
+		start := i + j
+		i = start + 1
+
+		left := i < len(pattern) && pattern[i] == '-'
+		if left {
+			i++
+		}
+		min, n := leadingInt(pattern[i:])
+		i += n
+		max := 0
+		if i < len(pattern) && pattern[i] == '.' {
+			if max, n = leadingInt(pattern[i+1:]); n == 0 {
+				return nil, patternError(pattern, start, "missing maximum width")
+			}
+			i += n + 1
+		}
+		if min > maxPatternWidth || max > maxPatternWidth {
+			return nil, patternError(pattern, start, fmt.Sprintf("width exceeds %d", maxPatternWidth))
+		}
+		if i == len(pattern) {
+			return nil, patternError(pattern, start, "missing verb")
+		}
+		verb := pattern[i]
+		i++
+
+		arg, hasArg := "", false
+		if i < len(pattern) && pattern[i] == '{' {
+			k := strings.IndexByte(pattern[i:], '}')
+			if k < 0 {
+				return nil, patternError(pattern, start, "unterminated argument")
+			}
+			arg, hasArg = pattern[i+1:i+k], true
+			i += k + 1
+		}
+
+		var a patternAppender
+		switch verb {
+		case 'd':
+			a = timeAppender(arg)
+		case 'p':
+			a = appendPatternLevel
+		case 'c':
+			a = appendPatternName
+		case 'F':
+			a = appendPatternFile
+		case 'L':
+			a = appendPatternLine
+		case 'M':
+			a = appendPatternFunc
+		case 'm':
+			a = appendPatternMsg
+		case 'X':
+			if len(arg) == 0 {
+				return nil, patternError(pattern, start, "%X needs a key such as %X{user}")
+			}
+			a = contextAppender(arg)
+		case 'n':
+			a = literalAppender("\n")
+		case '%':
+			a = literalAppender("%")
+		default:
+			return nil, patternError(pattern, start, fmt.Sprintf("unknown verb %%%c", verb))
+		}
+		if hasArg && verb != 'd' && verb != 'X' {
+			return nil, patternError(pattern, start, fmt.Sprintf("%%%c takes no argument", verb))
+		}
+		if min > 0 || max > 0 {
+			a = paddedAppender(a, left, min, max)
+		}
+		f.appenders = append(f.appenders, a)
+	}
+	return f, nil
+}
+
+func patternError(pattern string, offset int, reason string) error {
+	return fmt.Errorf("log: invalid pattern %q at offset %d: %s", pattern, offset, reason)
+}
+
+// maxPatternWidth bounds the widths of a pattern, since every entry is
+// padded to them
+const maxPatternWidth = 255
+
+// leadingInt parses the digits at the beginning of s, n is the number of
+// them, v stops growing once it exceeds maxPatternWidth
+func leadingInt(s string) (v, n int) {
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		if v <= maxPatternWidth {
+			v = v*10 + int(s[n]-'0')
+		}
+		n++
+	}
+	return v, n
+}
+
+func (p *PatternFormatter) SetColor(color bool) {
+	p.Color = color
+}
+
+func (p *PatternFormatter) withColor(color bool) Formatter {
+	f := *p
+	f.Color = color
+	return &f
+}
+
+// String returns the pattern
+func (p *PatternFormatter) String() string {
+	return p.pattern
+}
+
+func (p *PatternFormatter) Render(e *LogEntry) {
+	def, _ := levelDef(e.severity)
+	color := p.Color && len(def.Color) != 0
+	if color {
+		e.buf = append(e.buf, def.Color...)
+	}
+	for _, a := range p.appenders {
+		e.buf = a(e.buf, e)
+	}
+	if color {
+		if n := len(e.buf); n != 0 && e.buf[n-1] == '\n' {
+			e.buf = append(e.buf[:n-1], colorReset+"\n"...)
+		} else {
+			e.buf = append(e.buf, colorReset...)
+		}
+	}
+}
+
+func literalAppender(s string) patternAppender {
+	return func(dst []byte, _ *LogEntry) []byte {
+		return append(dst, s...)
+	}
+}
+
+func timeAppender(layout string) patternAppender {
+	if len(layout) == 0 {
+		return func(dst []byte, e *LogEntry) []byte {
+			e.SetTimestamp("")
+			return append(dst, e.timestamp...)
+		}
+	}
+	return func(dst []byte, e *LogEntry) []byte {
+		t := e.time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		return t.AppendFormat(dst, layout)
+	}
+}
+
+func appendPatternLevel(dst []byte, e *LogEntry) []byte {
+	return append(dst, e.level...)
+}
+
+func appendPatternName(dst []byte, e *LogEntry) []byte {
+	return append(dst, e.name...)
+}
+
+func appendPatternFile(dst []byte, e *LogEntry) []byte {
+	if len(e.file) == 0 {
+		return append(dst, '?')
+	}
+	return append(dst, e.file...)
+}
+
+func appendPatternLine(dst []byte, e *LogEntry) []byte {
+	if len(e.file) == 0 {
+		return append(dst, '?')
+	}
+	return strconv.AppendInt(dst, int64(e.line), 10)
+}
+
+func appendPatternFunc(dst []byte, e *LogEntry) []byte {
+	if len(e.file) == 0 {
+		return append(dst, '?')
+	}
+	return append(dst, e.funcname...)
+}
+
+func appendPatternMsg(dst []byte, e *LogEntry) []byte {
+	return append(dst, strings.TrimSuffix(e.msg, "\n")...)
+}
+
+// contextAppender appends the value of key, fields of the entry take
+// precedence over its context
+func contextAppender(key string) patternAppender {
+	return func(dst []byte, e *LogEntry) []byte {
+		for i := len(e.fields) - 1; i >= 0; i-- {
+			if f := e.fields[i]; f.Key == key {
+				if f.typ == stringType {
+					return append(dst, f.str...)
+				}
+				return f.appendText(dst)
+			}
+		}
+		v, ok := e.context[key]
+		if !ok {
+			return dst
+		}
+		if s, ok := v.(string); ok {
+			return append(dst, s...)
+		}
+		return appendTextValue(dst, v)
+	}
+}
+
+// paddedAppender pads the output of a to min runes and cuts it to the
+// last max runes
+func paddedAppender(a patternAppender, left bool, min, max int) patternAppender {
+	return func(dst []byte, e *LogEntry) []byte {
+		start := len(dst)
+		dst = a(dst, e)
+
+		n := utf8.RuneCount(dst[start:])
+		if max > 0 && n > max {
+			cut := start
+			for ; n > max; n-- {
+				_, size := utf8.DecodeRune(dst[cut:])
+				cut += size
+			}
+			dst = append(dst[:start], dst[cut:]...)
+		}
+		if pad := min - n; pad > 0 {
+			end := len(dst)
+			for i := 0; i < pad; i++ {
+				dst = append(dst, ' ')
+			}
+			if !left {
+				copy(dst[start+pad:], dst[start:end])
+				for i := start; i < start+pad; i++ {
+					dst[i] = ' '
+				}
+			}
+		}
+		return dst
+	}
+}
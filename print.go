@@ -17,92 +17,274 @@ package log
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
 )
 
-func (e *LogEntry) SetArgs(v []any) {
+// SetArgs prints v as the message of the entry, operands are separated
+// by spaces just like fmt.Sprintln but without the trailing newline
+func (e *LogEntry) SetArgs(v []any) *LogEntry {
 	for argNum, arg := range v {
 		if argNum > 0 {
-			e.buf = append(e.buf, ' ')
+			e.msgbuf = append(e.msgbuf, ' ')
 		}
 		e.println(arg)
 	}
-	e.buf = append(e.buf, '\n')
+	// NOTE: msgbuf is not modified until reset
+	e.msg = bytesToString(e.msgbuf)
+	return e
 }
 
-const (
-	signed   bool = true
-	unsigned bool = false
-)
-
+// println prints primitive values without reflection, the others are
+// printed by print with the layout of the %v verb
 func (e *LogEntry) println(arg any) {
-	// e.arg = arg
-	// e.val = reflect.Value{}
-
 	switch f := arg.(type) {
+	case nil:
+		e.printString(nilString)
 	case bool:
-		e.printBool()
-	case float32:
-		e.printFloat(32)
+		e.printBool(f)
 	case int:
-		e.printInt(8, signed)
+		e.printInt(int64(f))
+	case int8:
+		e.printInt(int64(f))
+	case int16:
+		e.printInt(int64(f))
+	case int32:
+		e.printInt(int64(f))
+	case int64:
+		e.printInt(f)
 	case uint:
-		e.printInt(8, unsigned)
+		e.printUint(uint64(f))
+	case uint8:
+		e.printUint(uint64(f))
+	case uint16:
+		e.printUint(uint64(f))
+	case uint32:
+		e.printUint(uint64(f))
+	case uint64:
+		e.printUint(f)
+	case uintptr:
+		e.printUint(uint64(f))
+	case float32:
+		e.printFloat(float64(f), 32)
+	case float64:
+		e.printFloat(f, 64)
+	case complex64:
+		e.printComplex(complex128(f), 64)
+	case complex128:
+		e.printComplex(f, 128)
 	case string:
 		e.printString(f)
 	case []byte:
 		e.printBytes(f)
+	case error:
+		e.printError(f)
+	case fmt.Stringer:
+		e.printStringer(f)
 	default:
-		e.print(reflect.ValueOf(f))
+		e.print(reflect.ValueOf(f), 0)
 	}
 }
 
-func (e *LogEntry) print(v reflect.Value) {
+const nilString = "<nil>"
+
+func (e *LogEntry) print(v reflect.Value, depth int) {
+	if !v.IsValid() {
+		e.printString(nilString)
+		return
+	}
+
+	// NOTE: values of unexported fields could not be converted to interfaces
+	if depth > 0 && v.CanInterface() {
+		switch f := v.Interface().(type) {
+		case error:
+			if !isNilValue(v) {
+				e.printError(f)
+				return
+			}
+		case fmt.Stringer:
+			if !isNilValue(v) {
+				e.printStringer(f)
+				return
+			}
+		}
+	}
+
 	switch v.Kind() {
+	case reflect.Bool:
+		e.printBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.printInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.printUint(v.Uint())
+	case reflect.Float32:
+		e.printFloat(v.Float(), 32)
+	case reflect.Float64:
+		e.printFloat(v.Float(), 64)
+	case reflect.Complex64:
+		e.printComplex(v.Complex(), 64)
+	case reflect.Complex128:
+		e.printComplex(v.Complex(), 128)
 	case reflect.String:
 		e.printString(v.String())
-	case reflect.Slice:
-		e.buf = append(e.buf, '[')
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			e.printBytes(v.Bytes())
+			return
+		}
+		e.msgbuf = append(e.msgbuf, '[')
 		for i := 0; i < v.Len(); i++ {
 			if i > 0 {
-				e.buf = append(e.buf, ' ')
+				e.msgbuf = append(e.msgbuf, ' ')
+			}
+			e.print(v.Index(i), depth+1)
+		}
+		e.msgbuf = append(e.msgbuf, ']')
+	case reflect.Map:
+		if v.IsNil() {
+			e.printString("map[]")
+			return
+		}
+		e.printString("map[")
+		for i, key := range sortedMapKeys(v) {
+			if i > 0 {
+				e.msgbuf = append(e.msgbuf, ' ')
 			}
-			e.print(v.Index(i))
+			e.print(key, depth+1)
+			e.msgbuf = append(e.msgbuf, ':')
+			e.print(v.MapIndex(key), depth+1)
 		}
-		e.buf = append(e.buf, ']')
+		e.msgbuf = append(e.msgbuf, ']')
+	case reflect.Struct:
+		e.msgbuf = append(e.msgbuf, '{')
+		for i := 0; i < v.NumField(); i++ {
+			if i > 0 {
+				e.msgbuf = append(e.msgbuf, ' ')
+			}
+			e.print(v.Field(i), depth+1)
+		}
+		e.msgbuf = append(e.msgbuf, '}')
 	case reflect.Interface:
-		val := v.Elem()
-		if val.IsValid() {
-			e.print(val)
+		e.print(v.Elem(), depth+1)
+	case reflect.Pointer:
+		// NOTE: only the top level pointer is dereferenced, so cycles are
+		// never followed
+		if depth == 0 && !v.IsNil() {
+			switch v.Elem().Kind() {
+			case reflect.Array, reflect.Slice, reflect.Struct, reflect.Map:
+				e.msgbuf = append(e.msgbuf, '&')
+				e.print(v.Elem(), depth+1)
+				return
+			}
 		}
+		e.printPointer(v)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		e.printPointer(v)
+	default:
+		e.printString(fmt.Sprint(v))
+	}
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// sortedMapKeys sorts keys of basic kinds like fmt does, keys of other
+// kinds are kept in the order of iteration
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	if len(keys) == 0 {
+		return keys
+	}
 
+	var less func(a, b reflect.Value) bool
+	switch keys[0].Kind() {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	case reflect.Bool:
+		less = func(a, b reflect.Value) bool { return !a.Bool() && b.Bool() }
 	default:
-		fmt.Println("unsupported type: ", v.Kind())
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
+
+func (e *LogEntry) printPointer(v reflect.Value) {
+	if v.IsNil() {
+		e.printString(nilString)
+		return
+	}
+	e.msgbuf = append(e.msgbuf, '0', 'x')
+	e.msgbuf = strconv.AppendUint(e.msgbuf, uint64(v.Pointer()), 16)
+}
+
+// printBytes prints b as text if it is printable, otherwise as hex
+func (e *LogEntry) printBytes(b []byte) {
+	if isPrintable(b) {
+		e.msgbuf = append(e.msgbuf, b...)
+		return
+	}
+	for _, c := range b {
+		e.msgbuf = append(e.msgbuf, hex[c>>4], hex[c&0xF])
+	}
+}
+
+func isPrintable(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			return false
+		}
+		if !strconv.IsPrint(r) && r != '\n' && r != '\t' {
+			return false
+		}
+		b = b[size:]
 	}
+	return true
+}
+
+func (e *LogEntry) printBool(b bool) {
+	e.msgbuf = strconv.AppendBool(e.msgbuf, b)
 }
 
-func (e *LogEntry) printBytes(f []byte) {
-	println("bytes to be implemented")
+func (e *LogEntry) printFloat(f float64, size int) {
+	e.msgbuf = strconv.AppendFloat(e.msgbuf, f, 'g', -1, size)
 }
 
-func (e *LogEntry) printBool() {
-	println("bool")
-	todo()
+func (e *LogEntry) printComplex(c complex128, size int) {
+	e.msgbuf = append(e.msgbuf, strconv.FormatComplex(c, 'g', -1, size)...)
 }
 
-func (e *LogEntry) printFloat(size int) {
-	println("float")
-	todo()
+func (e *LogEntry) printInt(i int64) {
+	e.msgbuf = strconv.AppendInt(e.msgbuf, i, 10)
 }
 
-func (e *LogEntry) printInt(size int, signed bool) {
-	println("int")
-	todo()
+func (e *LogEntry) printUint(u uint64) {
+	e.msgbuf = strconv.AppendUint(e.msgbuf, u, 10)
 }
 
 func (e *LogEntry) printString(s string) {
-	e.buf = append(e.buf, s...)
+	e.msgbuf = append(e.msgbuf, s...)
+}
+
+// printError and printStringer rely on fmt to recover from panics and
+// to handle nil receivers
+func (e *LogEntry) printError(err error) {
+	e.msgbuf = fmt.Append(e.msgbuf, err)
 }
 
-func todo() {
-	println("to be implemented")
+func (e *LogEntry) printStringer(s fmt.Stringer) {
+	e.msgbuf = fmt.Append(e.msgbuf, s)
 }
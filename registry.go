@@ -0,0 +1,185 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// levelInherit means a named logger follows the level of the default logger
+const levelInherit Level = -1
+
+// registry keeps loggers by name, the empty name refers to fastlogger.
+// levels are the levels set on prefixes of names by SetLevelFor.
+var registry = struct {
+	sync.RWMutex
+	loggers map[string]*Logger
+	levels  map[string]Level
+}{
+	loggers: map[string]*Logger{},
+	levels:  map[string]Level{},
+}
+
+// Register makes l reachable by name, e.g. by LevelHandler, a logger
+// registered with the same name is replaced
+func Register(name string, l *Logger) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.loggers[name] = l
+}
+
+// Lookup returns the logger registered with name, the empty name returns
+// the default logger
+func Lookup(name string) (*Logger, bool) {
+	if len(name) == 0 {
+		return fastlogger, true
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	l, ok := registry.loggers[name]
+	return l, ok
+}
+
+// Named returns the logger of a dotted name such as "db.pool", it is created
+// and registered on the first call. A named logger shares the writer and
+// formatter of the current default logger, writes its name as the "logger" field and
+// takes the level set on the longest prefix of its name by SetLevelFor,
+// e.g. "db" for "db.pool", or the level of the default logger otherwise.
+func Named(name string) *Logger {
+	registry.RLock()
+	l, ok := registry.loggers[name]
+	registry.RUnlock()
+	if ok {
+		return l
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if l, ok := registry.loggers[name]; ok {
+		return l
+	}
+
+	l = fastlogger.WithContext(Context{"logger": name})
+	l.name = name
+	l.lvl = &atomic.Int32{}
+	l.lvl.Store(int32(prefixLevel(name)))
+	registry.loggers[name] = l
+	return l
+}
+
+// SetLevelFor sets the level of the named loggers of prefix and all their
+// descendants, unless a longer prefix has its own level. The empty prefix
+// sets the level of the default logger.
+func SetLevelFor(prefix string, level Level) {
+	if len(prefix) == 0 {
+		fastlogger.SetLevel(level)
+		return
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.levels[prefix] = level
+	applyPrefixLevels()
+}
+
+// ResetLevelFor removes the level set on prefix, the named loggers of it
+// take the level of a shorter prefix again
+func ResetLevelFor(prefix string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.levels, prefix)
+	applyPrefixLevels()
+}
+
+// hasLevelFor reports whether prefix has its own level set by SetLevelFor
+func hasLevelFor(prefix string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	_, ok := registry.levels[prefix]
+	return ok
+}
+
+// SetLevelSpec sets levels of prefixes from a comma separated spec such
+// as "db=DEBUG,http.client=WARN", a prefix without name such as "=INFO"
+// sets the level of the default logger
+func SetLevelSpec(spec string) error {
+	levels := map[string]Level{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		prefix, name, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("log: invalid level spec %q, expected prefix=LEVEL", item)
+		}
+		level, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return fmt.Errorf("%w of prefix %q", err, prefix)
+		}
+		levels[strings.TrimSpace(prefix)] = level
+	}
+
+	for prefix, level := range levels {
+		SetLevelFor(prefix, level)
+	}
+	return nil
+}
+
+// prefixLevel returns the level set on the longest prefix of name,
+// registry must be locked
+func prefixLevel(name string) Level {
+	for prefix := name; len(prefix) != 0; {
+		if level, ok := registry.levels[prefix]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return levelInherit
+}
+
+// applyPrefixLevels updates the levels of all named loggers, registry must
+// be locked
+func applyPrefixLevels() {
+	for name, l := range registry.loggers {
+		if l.lvl != nil {
+			l.lvl.Store(int32(prefixLevel(name)))
+		}
+	}
+}
+
+// registeredNames returns the names of all loggers including the default
+// one in order
+func registeredNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := []string{""}
+	for name := range registry.loggers {
+		if len(name) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
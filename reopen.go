@@ -0,0 +1,88 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"os"
+	"sync"
+)
+
+// ReopenWriter is an io.Writer of a file which could be reopened, so that
+// the file could be rotated by an external tool such as logrotate with the
+// "create" mode. Writes and reopening never interleave, every write goes
+// either to the old file or to the new one as a whole.
+type ReopenWriter struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewReopenWriter opens path for appending, DefaultLogFile is used if
+// path is empty
+func NewReopenWriter(path string) (*ReopenWriter, error) {
+	if len(path) == 0 {
+		path = DefaultLogFile
+	}
+	f, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReopenWriter{path: path, file: f}, nil
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+func (w *ReopenWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	return w.file.Write(p)
+}
+
+// Reopen closes the file and opens path again, the old file is kept if
+// path could not be opened
+func (w *ReopenWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	f, err := openAppend(w.path)
+	if err != nil {
+		return err
+	}
+	old := w.file
+	w.file = f
+	return old.Close()
+}
+
+func (w *ReopenWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
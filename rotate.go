@@ -0,0 +1,420 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateInterval is the period of time based rotation
+type RotateInterval int
+
+const (
+	RotateNever RotateInterval = iota
+	RotateHourly
+	RotateDaily
+)
+
+var rotateIntervalNames = []string{"never", "hourly", "daily"}
+
+func (i RotateInterval) String() string {
+	if i >= 0 && int(i) < len(rotateIntervalNames) {
+		return rotateIntervalNames[i]
+	}
+	return "RotateInterval(" + strconv.Itoa(int(i)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (i RotateInterval) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the empty text means
+// RotateNever
+func (i *RotateInterval) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*i = RotateNever
+		return nil
+	}
+	for n, name := range rotateIntervalNames {
+		if strings.EqualFold(name, string(text)) {
+			*i = RotateInterval(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("log: unknown rotate interval %q", text)
+}
+
+// RotateOptions decides when RotateWriter rotates and which files it keeps
+type RotateOptions struct {
+	// MaxSize is the size in bytes of a file which triggers rotation,
+	// zero means no size based rotation
+	MaxSize int64
+	// Interval rotates at every hour or midnight of the local time
+	Interval RotateInterval
+	// MaxBackups is the number of rotated files to keep, zero keeps all
+	MaxBackups int
+	// MaxAge removes rotated files which were last written, i.e. rotated
+	// out, longer ago than it, zero keeps all
+	MaxAge time.Duration
+	// Compress gzips rotated files in the background
+	Compress bool
+}
+
+// rotateTimeLayout is the timestamp in the name of log files, the files
+// of a path such as "./access.log" are named "./access-2024-03-08T16-30-00.000.log",
+// files created in the same millisecond are numbered such as
+// "./access-2024-03-08T16-30-00.000-1.log"
+const rotateTimeLayout = "2006-01-02T15-04-05.000"
+
+const compressSuffix = ".gz"
+
+// RotateWriter is an io.Writer which writes to a file named by path and
+// the time of its creation, path itself is maintained as a symlink to the
+// current file. It is safe for concurrent use.
+type RotateWriter struct {
+	path string
+	opts RotateOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// next is the time of the next time based rotation
+	next time.Time
+
+	// mill is notified to compress and remove rotated files
+	mill     chan struct{}
+	millDone chan struct{}
+
+	now func() time.Time
+}
+
+// NewRotateWriter opens a new file for path, DefaultLogFile is used if
+// path is empty
+func NewRotateWriter(path string, opts RotateOptions) (*RotateWriter, error) {
+	if len(path) == 0 {
+		path = DefaultLogFile
+	}
+	w := &RotateWriter{
+		path:     path,
+		opts:     opts,
+		mill:     make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+		now:      time.Now,
+	}
+	if err := w.openNew(); err != nil {
+		return nil, err
+	}
+	go w.millDaemon()
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	var rerr error
+	sizeExceeded := w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize
+	intervalExceeded := !w.next.IsZero() && !w.now().Before(w.next)
+	if sizeExceeded || intervalExceeded {
+		// NOTE: p still goes to the current file if the rotation fails, the
+		// error is returned after the write
+		rerr = w.rotate()
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+// Rotate closes the current file and opens a new one
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// Close closes the current file and waits for the background compression
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.file == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	w.mu.Unlock()
+
+	// NOTE: the mill daemon takes mu, so it must be waited without mu
+	close(w.mill)
+	<-w.millDone
+	return err
+}
+
+// rotate opens a new file before closing the current one, so a failed
+// rotation leaves the current file in use and is retried by the next Write
+func (w *RotateWriter) rotate() error {
+	old := w.file
+	if err := w.openNew(); err != nil {
+		return err
+	}
+	err := old.Close()
+
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+func (w *RotateWriter) openNew() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+
+	now := w.now()
+	name := w.freeName(now)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.file = f
+	w.size = 0
+	w.next = w.nextRotation(now)
+
+	// NOTE: the symlink is best effort, e.g. it is not permitted on Windows
+	_ = w.link(name)
+	return nil
+}
+
+// link points path to name, a regular file at path is kept as a backup
+func (w *RotateWriter) link(name string) error {
+	if info, err := os.Lstat(w.path); err == nil && info.Mode()&fs.ModeSymlink == 0 {
+		if err := os.Rename(w.path, w.freeName(info.ModTime())); err != nil {
+			return err
+		}
+	}
+
+	tmp := w.path + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(name), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// fileName returns the name of the file created at t, seq numbers the
+// files created in the same millisecond
+func (w *RotateWriter) fileName(t time.Time, seq int) string {
+	prefix, ext := w.nameParts()
+	if seq == 0 {
+		return prefix + t.Format(rotateTimeLayout) + ext
+	}
+	return prefix + t.Format(rotateTimeLayout) + "-" + strconv.Itoa(seq) + ext
+}
+
+// freeName returns the first name of a file created at t which is used by
+// neither a file nor its compressed backup
+func (w *RotateWriter) freeName(t time.Time) string {
+	for seq := 0; ; seq++ {
+		name := w.fileName(t, seq)
+		if _, err := os.Lstat(name); err == nil {
+			continue
+		}
+		if _, err := os.Lstat(name + compressSuffix); err == nil {
+			continue
+		}
+		return name
+	}
+}
+
+// nameParts returns the prefix and the extension of the names of files
+func (w *RotateWriter) nameParts() (string, string) {
+	ext := filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "-", ext
+}
+
+func (w *RotateWriter) nextRotation(now time.Time) time.Time {
+	switch w.opts.Interval {
+	case RotateHourly:
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
+	case RotateDaily:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+func (w *RotateWriter) millDaemon() {
+	defer close(w.millDone)
+	for range w.mill {
+		if err := w.millOnce(); err != nil {
+			fmt.Fprintf(os.Stderr, "log: failed to clean up rotated files of %s: %s\n", w.path, err)
+		}
+	}
+}
+
+// backupFile is a rotated file, t and seq come from its name and order
+// the backups, mod is the time it was last written, i.e. rotated out
+type backupFile struct {
+	path string
+	t    time.Time
+	seq  int
+	mod  time.Time
+}
+
+// millOnce compresses and removes rotated files according to the options
+func (w *RotateWriter) millOnce() error {
+	w.mu.Lock()
+	var current string
+	if w.file != nil {
+		current = w.file.Name()
+	}
+	w.mu.Unlock()
+
+	backups, err := w.backups(current)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cutoff := w.now().Add(-w.opts.MaxAge)
+	for i, b := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && b.mod.Before(cutoff)) {
+			errs = append(errs, os.Remove(b.path))
+			continue
+		}
+		if w.opts.Compress && !strings.HasSuffix(b.path, compressSuffix) {
+			errs = append(errs, compressFile(b.path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backups returns rotated files of path from the newest to the oldest
+func (w *RotateWriter) backups(current string) ([]backupFile, error) {
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ext := w.nameParts()
+	prefix = filepath.Base(prefix)
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		path := filepath.Join(filepath.Dir(w.path), name)
+		if path == current {
+			continue
+		}
+		ts := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(name, compressSuffix), ext), prefix)
+		if len(ts) < len(rotateTimeLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(rotateTimeLayout, ts[:len(rotateTimeLayout)], time.Local)
+		if err != nil {
+			continue
+		}
+		var seq int
+		if suffix := ts[len(rotateTimeLayout):]; len(suffix) != 0 {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(suffix, "-")); err != nil || suffix[0] != '-' {
+				continue
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: path, t: t, seq: seq, mod: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].t.Equal(backups[j].t) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].t.After(backups[j].t)
+	})
+	return backups, nil
+}
+
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	// NOTE: the compressed file keeps the rotation time for MaxAge
+	if err = os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path+compressSuffix); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// samplerSize is the number of counters, entries whose level and message
+// collide share a counter
+const samplerSize = 4096
+
+// sampler writes the first initial entries of the same level and message
+// in every tick, and every thereafter-th entry beyond that
+type sampler struct {
+	tick       int64
+	initial    uint64
+	thereafter uint64
+	counters   [samplerSize]samplerCounter
+}
+
+type samplerCounter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+// SetSampling limits repeated entries, in every tick the first initial
+// entries of the same level and message are written and then every
+// thereafter-th of them, zero thereafter drops all of them. FATAL entries
+// are never dropped. A non positive initial or tick disables sampling.
+func (l *Logger) SetSampling(tick time.Duration, initial, thereafter int) *Logger {
+	var s *sampler
+	if tick > 0 && initial > 0 {
+		s = &sampler{tick: int64(tick), initial: uint64(initial)}
+		if thereafter > 0 {
+			s.thereafter = uint64(thereafter)
+		}
+	}
+	l.core().sampler.Store(s)
+	return l
+}
+
+// sampled reports whether an entry is kept by the sampler
+func (l *Logger) sampled(level Level, msg string) bool {
+	s := l.core().sampler.Load()
+	return s == nil || level >= LogLevelFatal || s.sample(level, msg)
+}
+
+func (s *sampler) sample(level Level, msg string) bool {
+	// NOTE: inlined FNV-1a, hashing the level and then the message
+	h := uint32(2166136261) ^ uint32(level)
+	h *= 16777619
+	for i := 0; i < len(msg); i++ {
+		h ^= uint32(msg[i])
+		h *= 16777619
+	}
+	c := &s.counters[h%samplerSize]
+
+	now := time.Now().UnixNano()
+	if resetAt := c.resetAt.Load(); now >= resetAt && c.resetAt.CompareAndSwap(resetAt, now+s.tick) {
+		c.n.Store(0)
+	}
+
+	n := c.n.Add(1)
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter != 0 && (n-s.initial)%s.thereafter == 0
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix
+
+package log
+
+// WatchLevelSignals does nothing since SIGUSR1 and SIGUSR2 are not
+// available on this platform
+func (l *Logger) WatchLevelSignals() (stop func()) {
+	return func() {}
+}
+
+// ReopenOnSIGHUP does nothing since SIGHUP is not available on this
+// platform, the file could be reopened by Reopen
+func (w *ReopenWriter) ReopenOnSIGHUP() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a strings.Builder which could be read while being written
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_WatchLevelSignals(t *testing.T) {
+	buf := &syncBuffer{}
+	logger := NewLogger(buf, LogLevelInfo, 0)
+	stop := logger.WatchLevelSignals()
+	defer stop()
+
+	waitLevel := func(sig syscall.Signal, expected Level) {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(buf.String(), "to "+getLogLevel(expected)+" by") {
+			if time.Now().After(deadline) {
+				t.Fatalf("level was not changed to %s, output %q", getLogLevel(expected), buf.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitLevel(syscall.SIGUSR1, LogLevelDebug)
+	if !logger.Enabled(LogLevelDebug) {
+		t.Fatal("debug should be enabled after SIGUSR1")
+	}
+	waitLevel(syscall.SIGUSR2, LogLevelInfo)
+	waitLevel(syscall.SIGUSR2, LogLevelWarn)
+	waitLevel(syscall.SIGUSR2, LogLevelError)
+	if logger.Enabled(LogLevelWarn) {
+		t.Fatal("warn should be disabled after SIGUSR2")
+	}
+	if n := strings.Count(buf.String(), "log level changed"); n != 4 {
+		t.Fatalf("every change should be logged once, got %d in %q", n, buf.String())
+	}
+}
+
+func Test_ReopenOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := NewReopenWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	stop := w.ReopenOnSIGHUP()
+	defer stop()
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("file was not reopened on SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -14,29 +14,163 @@
 
 package log
 
-/*
-import "bytes"
+import (
+	"sync"
+	"sync/atomic"
+)
 
-type logEvent struct {
-	fields Fields
-	ctx    Context
+// OverflowPolicy decides what an async logger does when its queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the caller until there is room in the queue
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the entry being logged
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest entry of the queue
+	OverflowDropOldest
+)
+
+// DefaultAsyncQueueSize is the number of entries buffered by EnableAsync
+const DefaultAsyncQueueSize = 1024
+
+// asyncQueue is a bounded ring buffer of rendered entries, it is drained
+// by a single daemon goroutine
+type asyncQueue struct {
+	mu sync.Mutex
+	// notEmpty wakes up the daemon, notFull wakes up blocked callers and
+	// idle wakes up callers of flush
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+
+	slots  []queuedEntry
+	head   int
+	size   int
+	policy OverflowPolicy
+
+	// writing is true while the daemon is writing an entry out of slots
+	writing bool
+	closed  bool
+	err     error
+	done    chan struct{}
+
+	// dropped is owned by the logger, so that it survives the queue
+	dropped *atomic.Uint64
 }
 
-// Sink defines a Sink
-type Sink interface {
-	Receiver() chan logEvent
-	Output() error
+// queuedEntry is a rendered entry and its level
+type queuedEntry struct {
+	level Level
+	buf   []byte
 }
 
-type StdioSink struct {
-	buf bytes.Buffer
+func newAsyncQueue(size int, policy OverflowPolicy, dropped *atomic.Uint64) *asyncQueue {
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	q := &asyncQueue{
+		slots:   make([]queuedEntry, size),
+		policy:  policy,
+		done:    make(chan struct{}),
+		dropped: dropped,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+	return q
 }
 
-func (s *StdioSink) Receiver() chan logEvent {
-	return make(chan logEvent)
+// push copies b into the queue, it returns false if the queue is closed
+func (q *asyncQueue) push(level Level, b []byte) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size == len(q.slots) && !q.closed {
+		switch q.policy {
+		case OverflowDropNewest:
+			q.dropped.Add(1)
+			return true
+		case OverflowDropOldest:
+			q.head = (q.head + 1) % len(q.slots)
+			q.size--
+			q.dropped.Add(1)
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return false
+	}
+
+	tail := (q.head + q.size) % len(q.slots)
+	q.slots[tail].level = level
+	q.slots[tail].buf = append(q.slots[tail].buf[:0], b...)
+	q.size++
+	q.notEmpty.Signal()
+	return true
 }
 
-func (s *StdioSink) Output() error {
-	return nil
+// pop swaps the oldest entry with spare, so that both buffers are reused,
+// it returns false once the queue is closed and drained
+func (q *asyncQueue) pop(spare []byte) (Level, []byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if q.size == 0 {
+		return LogLevelUnspecified, nil, false
+	}
+
+	level, b := q.slots[q.head].level, q.slots[q.head].buf
+	q.slots[q.head].buf = spare[:0]
+	q.head = (q.head + 1) % len(q.slots)
+	q.size--
+	q.writing = true
+	q.notFull.Signal()
+	return level, b, true
+}
+
+// written marks the end of writing the entry returned by pop
+func (q *asyncQueue) written(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.writing = false
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+	if q.size == 0 {
+		q.idle.Broadcast()
+	}
+}
+
+// flush waits until every queued entry is written, it returns the first
+// error of writing since the last flush
+func (q *asyncQueue) flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size != 0 || q.writing {
+		q.idle.Wait()
+	}
+	err := q.err
+	q.err = nil
+	return err
+}
+
+// close stops accepting entries and waits for the daemon to drain the queue
+func (q *asyncQueue) close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	return q.flush()
 }
-*/
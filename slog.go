@@ -0,0 +1,133 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slog levels of TRACE and FATAL, which are not defined by log/slog
+const (
+	SlogLevelTrace = slog.LevelDebug - 4
+	SlogLevelFatal = slog.LevelError + 4
+)
+
+// SlogHandler is a slog.Handler which writes records through a Logger, so
+// that they share the output and the format of the Logger, e.g.
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(logger)))
+//
+// Attributes are written as context fields, the keys of attributes in
+// groups are joined by dots. A record of SlogLevelFatal is written as
+// FATAL but the program does not exit.
+type SlogHandler struct {
+	l *Logger
+	// prefix is the groups opened by WithGroup, e.g. "request."
+	prefix string
+}
+
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+// levelFromSlog maps a slog level to the closest level not above it
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LogLevelTrace
+	case level < slog.LevelInfo:
+		return LogLevelDebug
+	case level < slog.LevelWarn:
+		return LogLevelInfo
+	case level < slog.LevelError:
+		return LogLevelWarn
+	case level < SlogLevelFatal:
+		return LogLevelError
+	}
+	return LogLevelFatal
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(levelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	levelGate := levelFromSlog(r.Level)
+	if vm := h.l.core().vmodule.Load(); vm != nil && r.PC != 0 && !h.l.enabledPC(vm, levelGate, r.PC) {
+		return nil
+	}
+	if !h.l.sampled(levelGate, r.Message) {
+		return nil
+	}
+	e := h.l.GetLogEntry().SetMsg(r.Message).setSeverity(levelGate).SetNewline().SetTime(r.Time)
+	defer h.l.PutLogEntry(e)
+
+	if r.NumAttrs() != 0 {
+		ctx := make(Context, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			addSlogAttr(ctx, h.prefix, a)
+			return true
+		})
+		e.WithContext(ctx)
+	}
+	if h.l.core().caller.Load() {
+		e.SetCaller(getFuncInfoFromPC(r.PC))
+	}
+
+	h.l.emit(e)
+	return nil
+}
+
+// WithAttrs returns a handler of a child logger, so that attrs are encoded
+// only once
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	ctx := make(Context, len(attrs))
+	for _, a := range attrs {
+		addSlogAttr(ctx, h.prefix, a)
+	}
+	return &SlogHandler{l: h.l.WithContext(ctx), prefix: h.prefix}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{l: h.l, prefix: h.prefix + name + "."}
+}
+
+// addSlogAttr adds a to ctx following the rules of slog.Handler: empty
+// attributes are ignored and groups without key are inlined
+func addSlogAttr(ctx Context, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		ctx[prefix+a.Key] = a.Value.Any()
+		return
+	}
+
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		addSlogAttr(ctx, prefix, ga)
+	}
+}
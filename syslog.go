@@ -0,0 +1,312 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFacility is the facility of syslog messages
+type SyslogFacility int
+
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+)
+
+const (
+	FacilityLocal0 SyslogFacility = iota + 16
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// SyslogProtocol is the message format of SyslogFormatter
+type SyslogProtocol int
+
+const (
+	// RFC5424 is "<14>1 2024-03-08T16:30:00.000000+08:00 host app 1234 - [ctx@32473 shard="3"] msg"
+	RFC5424 SyslogProtocol = iota
+	// RFC3164 is "<14>Mar  8 16:30:00 host app[1234]: msg shard=3"
+	RFC3164
+)
+
+// DefaultSyslogSDID is the SD-ID of the context, 32473 is the private
+// enterprise number reserved for documentation
+const DefaultSyslogSDID = "ctx@32473"
+
+const syslogNil = "-"
+
+// SyslogFormatter renders entries as syslog messages, the priority comes
+// from the syslog severity of the level, see LevelDef. With RFC5424 the
+// caller and context are written as STRUCTURED-DATA, with RFC3164 they
+// follow the message as logfmt pairs.
+type SyslogFormatter struct {
+	Protocol SyslogProtocol
+	// Facility is FacilityUser if it is zero, since FacilityKern is
+	// reserved for the kernel
+	Facility SyslogFacility
+	// Hostname is the name of this host if it is empty
+	Hostname string
+	// AppName is the name of the executable if it is empty
+	AppName string
+	// MsgID is written by RFC5424 only, "-" if it is empty
+	MsgID string
+	// SDID is DefaultSyslogSDID if it is empty
+	SDID  string
+	Color bool
+}
+
+var (
+	syslogHostname = sync.OnceValue(func() string {
+		h, _ := os.Hostname()
+		return h
+	})
+	syslogAppName = sync.OnceValue(func() string {
+		return filepath.Base(os.Args[0])
+	})
+	syslogPID = sync.OnceValue(func() string {
+		return strconv.Itoa(os.Getpid())
+	})
+)
+
+func (s *SyslogFormatter) SetColor(color bool) {
+	s.Color = color
+}
+
+func (s *SyslogFormatter) withColor(color bool) Formatter {
+	f := *s
+	f.Color = color
+	return &f
+}
+
+// priority returns the PRI of an entry
+func (s *SyslogFormatter) priority(e *LogEntry) int {
+	facility := s.Facility
+	if facility == FacilityKern {
+		facility = FacilityUser
+	}
+	return int(facility)*8 + syslogSeverity(e)
+}
+
+// syslogSeverity returns the syslog severity of the level of an entry,
+// SyslogInfo for unknown levels
+func syslogSeverity(e *LogEntry) int {
+	if def, ok := levelDef(e.severity); ok {
+		return def.Syslog
+	}
+	return SyslogInfo
+}
+
+func (s *SyslogFormatter) Render(e *LogEntry) {
+	t := e.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	hostname, appName := s.Hostname, s.AppName
+	if len(hostname) == 0 {
+		hostname = syslogHostname()
+	}
+	if len(appName) == 0 {
+		appName = syslogAppName()
+	}
+
+	e.buf = append(e.buf, '<')
+	e.buf = strconv.AppendInt(e.buf, int64(s.priority(e)), 10)
+	e.buf = append(e.buf, '>')
+
+	if s.Protocol == RFC3164 {
+		e.buf = t.AppendFormat(e.buf, time.Stamp)
+		e.buf = append(e.buf, ' ')
+		e.buf = appendSyslogName(e.buf, hostname, 255)
+		e.buf = append(e.buf, ' ')
+		e.buf = appendSyslogTag(e.buf, appName)
+		e.buf = append(e.buf, '[')
+		e.buf = append(e.buf, syslogPID()...)
+		e.buf = append(e.buf, "]: "...)
+		e.buf = append(e.buf, strings.TrimSuffix(e.msg, "\n")...)
+		if len(e.file) != 0 {
+			e.buf = appendLogfmtCaller(e.buf, e)
+		}
+		e.buf = append(e.buf, e.encoded...)
+		e.buf = appendLogfmtContext(e.buf, e.context)
+		for i := range e.fields {
+			e.buf = appendLogfmtKey(e.buf, e.fields[i].Key)
+			e.buf = e.fields[i].appendLogfmt(e.buf)
+		}
+		e.buf = append(e.buf, '\n')
+		return
+	}
+
+	e.buf = append(e.buf, '1', ' ')
+	e.buf = t.AppendFormat(e.buf, "2006-01-02T15:04:05.000000Z07:00")
+	e.buf = append(e.buf, ' ')
+	e.buf = appendSyslogName(e.buf, hostname, 255)
+	e.buf = append(e.buf, ' ')
+	e.buf = appendSyslogName(e.buf, appName, 48)
+	e.buf = append(e.buf, ' ')
+	e.buf = append(e.buf, syslogPID()...)
+	e.buf = append(e.buf, ' ')
+	e.buf = appendSyslogName(e.buf, s.MsgID, 32)
+	e.buf = append(e.buf, ' ')
+
+	if len(e.file) == 0 && len(e.encoded) == 0 && len(e.context) == 0 && len(e.fields) == 0 {
+		e.buf = append(e.buf, syslogNil...)
+	} else {
+		sdid := s.SDID
+		if len(sdid) == 0 {
+			sdid = DefaultSyslogSDID
+		}
+		e.buf = append(e.buf, '[')
+		e.buf = appendSyslogName(e.buf, sdid, 32)
+		if len(e.file) != 0 {
+			e.buf = append(e.buf, " "+keyCaller+`="`...)
+			e.buf = appendSyslogEscaped(e.buf, e.file)
+			e.buf = append(e.buf, ':')
+			e.buf = strconv.AppendInt(e.buf, int64(e.line), 10)
+			e.buf = append(e.buf, `" `+keyFunc+`="`...)
+			e.buf = appendSyslogEscaped(e.buf, e.funcname)
+			e.buf = append(e.buf, '"')
+		}
+		e.buf = append(e.buf, e.encoded...)
+		e.buf = appendSyslogContext(e.buf, e.context)
+		for i := range e.fields {
+			e.buf = appendSyslogParamName(e.buf, e.fields[i].Key)
+			e.buf = e.fields[i].appendSyslog(e.buf)
+			e.buf = append(e.buf, '"')
+		}
+		e.buf = append(e.buf, ']')
+	}
+
+	e.buf = append(e.buf, ' ')
+	e.buf = append(e.buf, strings.TrimSuffix(e.msg, "\n")...)
+	e.buf = append(e.buf, '\n')
+}
+
+func (s *SyslogFormatter) encodeContext(dst []byte, ctx Context) []byte {
+	if s.Protocol == RFC3164 {
+		return appendLogfmtContext(dst, ctx)
+	}
+	return appendSyslogContext(dst, ctx)
+}
+
+// appendSyslogContext appends ctx as sorted SD-PARAMs, each of them is
+// preceded by a space
+func appendSyslogContext(dst []byte, ctx Context) []byte {
+	for _, k := range ctx.sortedKeys() {
+		dst = appendSyslogParamName(dst, k)
+		dst = appendSyslogValue(dst, ctx[k])
+		dst = append(dst, '"')
+	}
+	return dst
+}
+
+// appendSyslogParamName appends ` name="`, a PARAM-NAME is at most 32
+// printable ASCII characters except '=', ']' and '"'
+func appendSyslogParamName(dst []byte, k string) []byte {
+	dst = append(dst, ' ')
+	if isReservedKey(k) {
+		k = fieldsPrefix + k
+	}
+	if len(k) == 0 {
+		dst = append(dst, '_')
+	}
+	for i := 0; i < len(k) && i < 32; i++ {
+		if c := k[i]; c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"' {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return append(dst, '=', '"')
+}
+
+// appendSyslogEscaped appends s escaping '"', '\' and ']' by a backslash
+// as a PARAM-VALUE requires
+func appendSyslogEscaped(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', ']':
+			dst = append(dst, '\\', c)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// appendSyslogValue appends v as an escaped PARAM-VALUE without quotes
+func appendSyslogValue(dst []byte, v any) []byte {
+	return appendScalar(dst, v, appendSyslogEscaped)
+}
+
+func (f Field) appendSyslog(dst []byte) []byte {
+	switch f.typ {
+	case stringType:
+		return appendSyslogEscaped(dst, f.str)
+	case intType, uintType, boolType, floatType, durationType, timeType:
+		return f.appendText(dst)
+	}
+	return appendSyslogValue(dst, f.iface)
+}
+
+// appendSyslogName appends s as a header field of at most max printable
+// ASCII characters, the empty s is written as "-"
+func appendSyslogName(dst []byte, s string, max int) []byte {
+	if len(s) == 0 {
+		return append(dst, syslogNil...)
+	}
+	for i := 0; i < len(s) && i < max; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
+
+// appendSyslogTag appends s as a RFC 3164 TAG, which is at most 32
+// alphanumeric characters, others are replaced by '_'
+func appendSyslogTag(dst []byte, s string) []byte {
+	for i := 0; i < len(s) && i < 32; i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '-' || c == '.' {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogSockets are the local syslog sockets tried by turn
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+const (
+	// syslogDialTimeout bounds connecting to a syslog server and
+	// syslogWriteTimeout bounds sending one message
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+
+	// a failed connect is not retried before the backoff elapsed, which
+	// doubles from syslogMinBackoff up to syslogMaxBackoff
+	syslogMinBackoff = 500 * time.Millisecond
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// SyslogWriter sends every write as a syslog message, usually rendered by
+// SyslogFormatter. The network is one of
+//
+//	udp, udp4, udp6   one datagram per message
+//	tcp, tcp4, tcp6   octet counting framing of RFC 6587
+//	tls               octet counting framing over TLS, RFC 5425
+//	unixgram, unix    a local socket, unix is framed by newlines
+//	""                the local syslog socket such as /dev/log
+//
+// A broken connection is redialed at the next write, which is retried once.
+// While the server is unreachable, writes fail fast with the last error of
+// connecting until the backoff elapsed. It is safe for concurrent use.
+type SyslogWriter struct {
+	network string
+	addr    string
+	tls     *tls.Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	buf    []byte
+	closed bool
+
+	// err is the last error of connecting, which is returned until retryAt
+	err     error
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// NewSyslogWriter connects to a syslog server at addr, tlsConfig is used
+// by the tls network only
+func NewSyslogWriter(network, addr string, tlsConfig *tls.Config) (*SyslogWriter, error) {
+	switch network {
+	case "", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("log: unknown syslog network %q", network)
+	}
+	w := &SyslogWriter{network: network, addr: addr, tls: tlsConfig}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// reconnect dials the server unless a failed connect is backing off, it is
+// called with mu held
+func (w *SyslogWriter) reconnect() error {
+	now := time.Now()
+	if now.Before(w.retryAt) {
+		return w.err
+	}
+	if err := w.connect(); err != nil {
+		w.backoff = min(max(2*w.backoff, syslogMinBackoff), syslogMaxBackoff)
+		w.err, w.retryAt = err, now.Add(w.backoff)
+		return err
+	}
+	w.err, w.retryAt, w.backoff = nil, time.Time{}, 0
+	return nil
+}
+
+// connect dials the server, it is called with mu held
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch w.network {
+	case "":
+		conn, err = dialLocalSyslog(w.addr)
+	case "tls":
+		dialer := &net.Dialer{Timeout: syslogDialTimeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.addr, w.tls)
+	default:
+		conn, err = net.DialTimeout(w.network, w.addr, syslogDialTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("log: failed to connect to syslog: %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// dialLocalSyslog connects to addr, or the first local syslog socket
+// which accepts a connection
+func dialLocalSyslog(addr string) (net.Conn, error) {
+	sockets := syslogSockets
+	if len(addr) != 0 {
+		sockets = []string{addr}
+	}
+	var errs []error
+	for _, path := range sockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, path, syslogDialTimeout)
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Write sends p as one message, a trailing newline is removed
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	msg := p
+	if n := len(msg); n != 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		if w.conn == nil {
+			if err = w.reconnect(); err != nil {
+				return 0, err
+			}
+		}
+		w.buf = w.frame(w.buf[:0], msg)
+		_ = w.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err = w.conn.Write(w.buf); err == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+// frame appends msg framed for the transport
+func (w *SyslogWriter) frame(dst, msg []byte) []byte {
+	network := w.network
+	if network == "" {
+		network = w.conn.RemoteAddr().Network()
+	}
+	switch {
+	case strings.HasPrefix(network, "tcp"), network == "tls":
+		dst = strconv.AppendInt(dst, int64(len(msg)), 10)
+		dst = append(dst, ' ')
+		return append(dst, msg...)
+	case network == "unix":
+		dst = append(dst, msg...)
+		return append(dst, '\n')
+	}
+	return append(dst, msg...)
+}
+
+// Close closes the connection, later writes fail with os.ErrClosed
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
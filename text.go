@@ -0,0 +1,107 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// appendTextString appends s as a logfmt value, s is quoted if it is empty
+// or contains spaces, '=', quotes or non printable characters
+func appendTextString(dst []byte, s string) []byte {
+	if needsQuote(s, false) {
+		return strconv.AppendQuote(dst, s)
+	}
+	return append(dst, s...)
+}
+
+// needsQuote reports whether s is empty or contains spaces, '=', quotes,
+// non printable characters, or backslashes if backslash is set
+func needsQuote(s string, backslash bool) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if b <= ' ' || b == '=' || b == '"' || b == 0x7f || (backslash && b == '\\') {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError || !strconv.IsPrint(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appendTextValue appends v as a logfmt value
+func appendTextValue(dst []byte, v any) []byte {
+	return appendScalar(dst, v, appendTextString)
+}
+
+// appendScalar appends v as plain text. Strings, byte slices and the fmt
+// representation of values which are not scalars are appended by str,
+// which quotes or escapes them as the format requires, while the text of
+// booleans, numbers, times and durations never needs that.
+func appendScalar(dst []byte, v any, str func(dst []byte, s string) []byte) []byte {
+	switch f := v.(type) {
+	case nil:
+		return str(dst, "<nil>")
+	case string:
+		return str(dst, f)
+	case []byte:
+		return str(dst, bytesToString(f))
+	case bool:
+		return strconv.AppendBool(dst, f)
+	case int:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int8:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int16:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(f), 10)
+	case int64:
+		return strconv.AppendInt(dst, f, 10)
+	case uint:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint8:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint16:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(f), 10)
+	case uint64:
+		return strconv.AppendUint(dst, f, 10)
+	case float32:
+		return strconv.AppendFloat(dst, float64(f), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(dst, f, 'g', -1, 64)
+	case time.Time:
+		return f.AppendFormat(dst, time.RFC3339Nano)
+	case time.Duration:
+		return append(dst, f.String()...)
+	}
+	// NOTE: fmt recovers from panics in Error() and String()
+	return str(dst, fmt.Sprint(v))
+}
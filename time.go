@@ -27,10 +27,25 @@ const (
 	sepSpace = ' '
 )
 
+// timeLayoutFast is the layout of fastTimestamp
+const timeLayoutFast = "2006-01-02 15:04:05"
+
+// SetTime makes SetTimestamp use t instead of the current time
+func (e *LogEntry) SetTime(t time.Time) *LogEntry {
+	e.time = t
+	return e
+}
+
 func (e *LogEntry) SetTimestamp(format string) *LogEntry {
-	if len(format) != 0 {
-		e.timestamp = []byte(time.Now().Format(format))
-	} else {
+	switch {
+	case !e.time.IsZero() && len(format) != 0:
+		e.timestamp = e.time.AppendFormat(e.timestamp[:0], format)
+	case !e.time.IsZero():
+		// NOTE: keep the same location as fastTimestamp
+		e.timestamp = e.time.UTC().AppendFormat(e.timestamp[:0], timeLayoutFast)
+	case len(format) != 0:
+		e.timestamp = time.Now().AppendFormat(e.timestamp[:0], format)
+	default:
 		e.fastTimestamp()
 	}
 
@@ -41,7 +56,7 @@ func (e *LogEntry) fastTimestamp() *LogEntry {
 	// TODO: make timestamp configurable
 	// NOTE: this fast timestamp will be generated without location
 	// change time format from 2024-03-08T16:30:00Z to 2024-03-08 16:30:00
-	var tmp [20]byte
+	var tmp [19]byte
 
 	totalsec, _, _ := now()
 	totalsec += 9223372028715321600
@@ -100,8 +115,7 @@ func (e *LogEntry) fastTimestamp() *LogEntry {
 
 	// end
 
-	stmp := tmp[:20]
-	e.timestamp = append(e.timestamp, stmp...)
+	e.timestamp = append(e.timestamp, tmp[:]...)
 	return e
 }
 
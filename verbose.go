@@ -0,0 +1,81 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+// keyVerbosity is the key of the V level of an entry
+const keyVerbosity = "v"
+
+// Verbose logs at INFO level if its V level is enabled, e.g.
+//
+//	if v := logger.V(2); v.Enabled() {
+//		v.Infof("compacted %d segments\n", n)
+//	}
+type Verbose struct {
+	l       *Logger
+	level   int
+	enabled bool
+}
+
+// V returns a Verbose of level, which is enabled if level is not above the
+// verbosity of the logger and INFO is enabled
+func (l *Logger) V(level int) Verbose {
+	return Verbose{
+		l:       l,
+		level:   level,
+		enabled: int32(level) <= l.core().verbosity.Load() && l.Enabled(LogLevelInfo),
+	}
+}
+
+// SetVerbosity sets the highest V level to log, the default is 0
+func (l *Logger) SetVerbosity(v int) {
+	l.core().verbosity.Store(int32(v))
+}
+
+func (l *Logger) GetVerbosity() int {
+	return int(l.core().verbosity.Load())
+}
+
+func (v Verbose) Enabled() bool {
+	return v.enabled
+}
+
+func (v Verbose) Infof(format string, args ...any) {
+	if !v.enabled {
+		return
+	}
+	v.l._printv(v.level, formattedMessage(format, args...), false)
+}
+
+func (v Verbose) Infoln(msg string) {
+	if !v.enabled {
+		return
+	}
+	v.l._printv(v.level, msg, true)
+}
+
+// _printv writes msg at INFO level with the V level as a field
+func (l *Logger) _printv(level int, msg string, newline bool) {
+	if !l.enabled(LogLevelInfo) {
+		return
+	}
+
+	e := l.GetLogEntry().SetMsg(msg).setSeverity(LogLevelInfo).WithFields([]Field{Int(keyVerbosity, level)})
+	if newline {
+		e.SetNewline()
+	}
+	defer l.PutLogEntry(e)
+
+	l.output(LogLevelInfo, e)
+}
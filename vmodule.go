@@ -0,0 +1,158 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// vmodule overrides levels of call sites by the files they are in
+type vmodule struct {
+	rules []vmoduleRule
+	// min is the lowest level of rules, a level below both min and the
+	// level of the logger is never enabled
+	min int32
+	// max is the highest level of rules, a level at or above both max and
+	// the level of the logger is always enabled
+	max int32
+	// cache maps the pc of a call site to its level, levelInherit if no
+	// rule matches
+	cache sync.Map
+}
+
+type vmoduleRule struct {
+	pattern string
+	// depth is the number of trailing path elements matched by pattern
+	depth int
+	level int32
+}
+
+// parseVModule parses a comma separated spec of pattern=LEVEL
+func parseVModule(spec string) (*vmodule, error) {
+	vm := &vmodule{min: math.MaxInt32, max: math.MinInt32}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		pattern, name, ok := strings.Cut(item, "=")
+		if !ok || len(pattern) == 0 {
+			return nil, fmt.Errorf("log: invalid vmodule %q, expected pattern=LEVEL", item)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("log: invalid vmodule pattern %q: %w", pattern, err)
+		}
+		level, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("%w of vmodule pattern %q", err, pattern)
+		}
+
+		pattern = strings.TrimSuffix(pattern, ".go")
+		vm.rules = append(vm.rules, vmoduleRule{
+			pattern: pattern,
+			depth:   strings.Count(pattern, "/") + 1,
+			level:   int32(level),
+		})
+		vm.min = min(vm.min, int32(level))
+		vm.max = max(vm.max, int32(level))
+	}
+	if len(vm.rules) == 0 {
+		return nil, nil
+	}
+	return vm, nil
+}
+
+// level returns the level overriding the call site of pc
+func (vm *vmodule) level(pc uintptr) int32 {
+	if lv, ok := vm.cache.Load(pc); ok {
+		return lv.(int32)
+	}
+
+	lv := int32(levelInherit)
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	file := strings.TrimSuffix(frame.File, ".go")
+	for _, rule := range vm.rules {
+		if ok, _ := path.Match(rule.pattern, trailingElems(file, rule.depth)); ok {
+			lv = rule.level
+			break
+		}
+	}
+	vm.cache.Store(pc, lv)
+	return lv
+}
+
+// trailingElems returns the last n elements of a slash separated path
+func trailingElems(p string, n int) string {
+	i := len(p)
+	for ; n > 0 && i > 0; n-- {
+		i = strings.LastIndexByte(p[:i], '/')
+		if i < 0 {
+			return p
+		}
+	}
+	return p[i+1:]
+}
+
+// SetVModule overrides the level of call sites in files matching patterns,
+// e.g. "server=DEBUG,storage/*=TRACE". A pattern without slash is matched
+// against the file name without ".go", a pattern with slashes against the
+// same number of trailing path elements, the first matching pattern wins.
+// The level of a call site is cached by its program counter, so that the
+// files are only matched once. An empty spec removes all overrides.
+func (l *Logger) SetVModule(spec string) error {
+	vm, err := parseVModule(spec)
+	if err != nil {
+		return err
+	}
+	l.core().vmodule.Store(vm)
+	return nil
+}
+
+// enabled is the precise version of Enabled for _printf and the like, it
+// consults the vmodule overrides of the call site. Only levels which some
+// rule could decide either way take the stack walk of the call site, e.g.
+// DEBUG in every file with "server=DEBUG" at INFO, see Benchmark_VModule.
+func (l *Logger) enabled(level Level) bool {
+	vm := l.core().vmodule.Load()
+	threshold := l.threshold()
+	if vm == nil {
+		return int32(level) >= threshold
+	}
+	if int32(level) >= threshold && int32(level) >= vm.max {
+		return true
+	}
+	if int32(level) < threshold && int32(level) < vm.min {
+		return false
+	}
+
+	var pcs [1]uintptr
+	// NOTE: the caller is at the same depth seen from here as from output
+	if runtime.Callers(l.callerDepth(), pcs[:]) == 0 {
+		return int32(level) >= threshold
+	}
+	return l.enabledPC(vm, level, pcs[0])
+}
+
+func (l *Logger) enabledPC(vm *vmodule, level Level, pc uintptr) bool {
+	if lv := vm.level(pc); lv != int32(levelInherit) {
+		return int32(level) >= lv
+	}
+	return int32(level) >= l.threshold()
+}
@@ -0,0 +1,526 @@
+// Copyright 2024 mlycore. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RotateWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	clock := time.Date(2024, 3, 8, 16, 30, 0, 0, time.Local)
+	w, err := NewRotateWriter(path, RotateOptions{MaxSize: 20, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789abcdef\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := w.file.Name()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil || target != filepath.Base(current) {
+		t.Fatalf("symlink should point to %s, got %s (%v)", current, target, err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "access-*.log.gz"))
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 compressed backups, got %v", matches)
+	}
+	f, err := os.Open(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	if string(data) != "0123456789abcdef\n" {
+		t.Fatalf("unexpected content of backup %q", data)
+	}
+}
+
+func Test_RotateWriterBurst(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	clock := time.Date(2024, 3, 8, 16, 30, 0, 0, time.Local)
+	w, err := NewRotateWriter(path, RotateOptions{MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: every rotation happens in the same millisecond
+	w.now = func() time.Time { return clock }
+
+	line := []byte(strings.Repeat("x", 149) + "\n")
+	for i := 0; i < 50; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "access-*.log"))
+	if len(matches) != 50 {
+		t.Fatalf("expected 50 files, got %d", len(matches))
+	}
+	for _, name := range matches {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(line)) {
+			t.Fatalf("%s should hold one line, got %d bytes", name, info.Size())
+		}
+	}
+}
+
+func Test_RotateWriterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	clock := time.Now().Add(-48 * time.Hour)
+	w, err := NewRotateWriter(path, RotateOptions{MaxAge: time.Hour, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { return clock }
+	expired := w.fileName(clock.Add(-time.Hour), 0)
+	if err := os.WriteFile(expired, []byte("expired\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := clock.Add(-time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// NOTE: the file named two days ago is written until now
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	long := w.file.Name()
+	_, _ = w.Write([]byte("long lived\n"))
+	clock = time.Now()
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(long + compressSuffix); err != nil {
+		t.Fatalf("a file rotated out just now should be kept: %v", err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatalf("a file rotated out long ago should be removed: %v", err)
+	}
+}
+
+func Test_RotateWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	clock := time.Date(2024, 3, 8, 16, 59, 59, 0, time.Local)
+	w, err := NewRotateWriter(path, RotateOptions{Interval: RotateHourly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { return clock }
+	// NOTE: the first file was opened with the real clock
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = w.Write([]byte("before\n"))
+	clock = clock.Add(time.Second)
+	_, _ = w.Write([]byte("after\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "after\n" {
+		t.Fatalf("current file should only contain the line after rotation, got %q (%v)", data, err)
+	}
+	if !strings.HasSuffix(w.fileName(clock, 0), "access-2024-03-08T17-00-00.000.log") {
+		t.Fatalf("unexpected file name %s", w.fileName(clock, 0))
+	}
+	backup, err := os.ReadFile(w.fileName(clock.Add(-time.Second), 0))
+	if err != nil || string(backup) != "before\n" {
+		t.Fatalf("unexpected backup %q (%v)", backup, err)
+	}
+}
+
+func Test_RotateWriterFailedRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directories with open files can not be renamed on windows")
+	}
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "access.log")
+
+	clock := time.Date(2024, 3, 8, 16, 30, 0, 0, time.Local)
+	w, err := NewRotateWriter(path, RotateOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	first := w.file.Name()
+
+	// NOTE: a plain file in place of the directory makes the open fail
+	if err := os.Rename(dir, dir+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Write([]byte("second\n")); err == nil || n != len("second\n") {
+		t.Fatalf("a failed rotation should be reported after the write, got %d %v", n, err)
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(dir+".bak", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("third\n")); err != nil {
+		t.Fatalf("rotation should be retried after a failure, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "third\n" {
+		t.Fatalf("unexpected content of the current file %q (%v)", data, err)
+	}
+	if data, err = os.ReadFile(first); err != nil || string(data) != "first\nsecond\n" {
+		t.Fatalf("the entry of the failed rotation should be kept, got %q (%v)", data, err)
+	}
+}
+
+func Test_ReopenWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	w, err := NewReopenWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logger := NewLogger(w, LogLevelInfo, 0)
+
+	logger.Infoln("before rotation")
+	// NOTE: logrotate renames the file and lets the program create a new one
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Infoln("still in the old file")
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Infoln("after rotation")
+
+	old, _ := os.ReadFile(path + ".1")
+	cur, _ := os.ReadFile(path)
+	if strings.Count(string(old), "\n") != 2 || !strings.Contains(string(old), "msg=still in the old file") {
+		t.Fatalf("unexpected old file %q", old)
+	}
+	if strings.Count(string(cur), "\n") != 1 || !strings.Contains(string(cur), "msg=after rotation") {
+		t.Fatalf("unexpected new file %q", cur)
+	}
+}
+
+func Test_MultiWriter(t *testing.T) {
+	all, errs := &strings.Builder{}, &strings.Builder{}
+	logger := NewLogger(NewMultiWriter(
+		Output{Writer: all},
+		Output{Writer: errs, Level: LogLevelError},
+	), LogLevelDebug, 0)
+
+	logger.Debugln("debug")
+	logger.Errorln("error")
+	logger.EnableAsync()
+	logger.Warnln("async warn")
+	logger.Errorln("async error")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(all.String(), "\n"); n != 4 {
+		t.Fatalf("expected 4 entries, got %q", all.String())
+	}
+	if n := strings.Count(errs.String(), "\n"); n != 2 || strings.Contains(errs.String(), "WARN") {
+		t.Fatalf("expected 2 errors, got %q", errs.String())
+	}
+}
+
+func Test_SyslogWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := NewSyslogWriter("udp", pc.LocalAddr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logger := NewLogger(w, LogLevelInfo, 0)
+	logger.SetFormatter(&SyslogFormatter{Hostname: "host", AppName: "app"})
+	logger.Warnln("over udp")
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<12>1 ") || !strings.HasSuffix(msg, " - - over udp") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func Test_SyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	testSyslogStream(t, ln, "tcp", nil)
+}
+
+func Test_SyslogWriterTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	testSyslogStream(t, ln, "tls", &tls.Config{RootCAs: roots})
+}
+
+// testSyslogStream checks octet counting framing and reconnecting
+func Test_SyslogWriterBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	ln.Close()
+
+	// NOTE: writes could succeed until the reset of the peer arrives
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err = w.Write([]byte("<14>down\n")); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("writes to a closed server should fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := w.Write([]byte("<14>fast\n")); err == nil || err != w.err {
+		t.Fatalf("writes should fail fast with the last error, got %v", err)
+	}
+	if w.backoff != syslogMinBackoff || !w.retryAt.After(time.Now()) {
+		t.Fatalf("unexpected backoff %s until %s", w.backoff, w.retryAt)
+	}
+
+	w.retryAt = time.Time{}
+	if _, err := w.Write([]byte("<14>retry\n")); err == nil {
+		t.Fatal("reconnecting to a closed server should fail")
+	}
+	if w.backoff != 2*syslogMinBackoff {
+		t.Fatalf("backoff should double, got %s", w.backoff)
+	}
+}
+
+func testSyslogStream(t *testing.T, ln net.Listener, network string, config *tls.Config) {
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if tc, ok := conn.(*tls.Conn); ok {
+				_ = tc.Handshake()
+			}
+			conns <- conn
+		}
+	}()
+
+	w, err := NewSyslogWriter(network, ln.Addr().String(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logger := NewLogger(w, LogLevelInfo, 0)
+	logger.SetFormatter(&SyslogFormatter{Protocol: RFC3164, Hostname: "host", AppName: "app"})
+
+	conn := <-conns
+	logger.Infoln("first")
+	logger.Errorln("second line")
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{"first", "second line"} {
+		if msg := readOctetCounted(t, conn, r); !strings.HasSuffix(msg, "]: "+expected) {
+			t.Fatalf("unexpected message %q", msg)
+		}
+	}
+
+	// NOTE: writes to a connection closed by the peer may still succeed
+	// until the reset arrives, so keep writing until the new one is used
+	conn.Close()
+	var next net.Conn
+	for next == nil {
+		logger.Infoln("after reconnect")
+		select {
+		case next = <-conns:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer next.Close()
+	if msg := readOctetCounted(t, next, bufio.NewReader(next)); !strings.HasSuffix(msg, "]: after reconnect") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func readOctetCounted(t *testing.T, conn net.Conn, r *bufio.Reader) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	return string(msg)
+}
+
+func Test_SyslogWriterUnixgram(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram is not supported")
+	}
+	path := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	saved := syslogSockets
+	defer func() { syslogSockets = saved }()
+	syslogSockets = []string{filepath.Join(t.TempDir(), "missing.sock"), path}
+
+	w, err := NewSyslogWriter("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("<14>local\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); msg != "<14>local" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	w.Close()
+	if _, err := w.Write([]byte("closed")); err == nil {
+		t.Fatal("expected an error after Close")
+	}
+}